@@ -0,0 +1,215 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/finove/webrtctest/client"
+)
+
+// Admin janus admin/monitor 接口客户端
+type Admin struct {
+	js     *Janus
+	secret string
+}
+
+// NewAdmin 连接 janus admin/monitor 接口，server 如 ws://127.0.0.1:7188 或 http://127.0.0.1:7088/admin
+func (cli *Client) NewAdmin(server, adminSecret string) (adm *Admin, err error) {
+	var tr Transport
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	if tr, err = cli.dialServer(ctx, server, true); err != nil {
+		return
+	}
+	// admin 连接沿用客户端的 TLS、代理等配置，但不做断线重连
+	var acli = *cli
+	acli.Server = server
+	acli.Secret = ""
+	acli.auth = nil
+	acli.reconnect = ReconnectPolicy{}
+	adm = &Admin{
+		js:     newJanus(&acli, tr),
+		secret: adminSecret,
+	}
+	adm.js.startReader(tr)
+	adm.js.log(LevelInfo, "admin connected", F("server", server))
+	return
+}
+
+// Close 断开 admin 连接
+func (adm *Admin) Close() error {
+	return adm.js.Destroy()
+}
+
+// AdminHandleInfo handle_info 返回的 handle 详情，只解析常用字段，完整内容见 Raw
+type AdminHandleInfo struct {
+	SessionID      int64           `json:"session_id"`
+	SessionLastAct int64           `json:"session_last_activity"`
+	HandleID       int64           `json:"handle_id"`
+	OpaqueID       string          `json:"opaque_id,omitempty"`
+	Created        int64           `json:"created"`
+	Plugin         string          `json:"plugin"`
+	PluginSpecific json.RawMessage `json:"plugin_specific,omitempty"`
+	Flags          map[string]bool `json:"flags,omitempty"`
+	AgentCreated   int64           `json:"agent-created,omitempty"`
+	IceMode        string          `json:"ice-mode,omitempty"`
+	IceRole        string          `json:"ice-role,omitempty"`
+	SDPs           struct {
+		Profile string `json:"profile,omitempty"`
+		Local   string `json:"local,omitempty"`
+		Remote  string `json:"remote,omitempty"`
+	} `json:"sdps"`
+	Streams []AdminStream   `json:"streams,omitempty"`
+	Raw     json.RawMessage `json:"-"`
+}
+
+// AdminStream handle 的媒体流信息
+type AdminStream struct {
+	ID         int              `json:"id"`
+	Ready      int              `json:"ready"`
+	Components []AdminComponent `json:"components,omitempty"`
+}
+
+// AdminComponent 媒体流的 ICE/DTLS 组件状态
+type AdminComponent struct {
+	ID           int    `json:"id"`
+	State        string `json:"state"` // ICE 状态，如 connecting,connected,ready,failed
+	Connected    int64  `json:"connected,omitempty"`
+	SelectedPair string `json:"selected-pair,omitempty"`
+	Dtls         struct {
+		Fingerprint       string `json:"fingerprint,omitempty"`
+		RemoteFingerprint string `json:"remote-fingerprint,omitempty"`
+		DtlsRole          string `json:"dtls-role,omitempty"`
+		DtlsState         string `json:"dtls-state,omitempty"`
+		Retransmissions   int    `json:"retransmissions,omitempty"`
+		Valid             bool   `json:"valid,omitempty"`
+		SrtpProfile       string `json:"srtp-profile,omitempty"`
+		Ready             bool   `json:"ready,omitempty"`
+	} `json:"dtls"`
+}
+
+// AdminStatus get_status 返回的服务器运行参数
+type AdminStatus struct {
+	TokenAuth             bool `json:"token_auth"`
+	APISecret             bool `json:"api_secret"`
+	SessionTimeout        int  `json:"session_timeout"`
+	ReclaimSessionTimeout int  `json:"reclaim_session_timeout"`
+	CandidatesTimeout     int  `json:"candidates_timeout"`
+	LogLevel              int  `json:"log_level"`
+	LogTimestamps         bool `json:"log_timestamps"`
+	LogColors             bool `json:"log_colors"`
+	LockingDebug          bool `json:"locking_debug"`
+	RefcountDebug         bool `json:"refcount_debug"`
+	LibniceDebug          bool `json:"libnice_debug"`
+	MinNackQueue          int  `json:"min_nack_queue"`
+	NackOptimizations     bool `json:"nack-optimizations"`
+	NoMediaTimer          int  `json:"no_media_timer"`
+	SlowlinkThreshold     int  `json:"slowlink_threshold"`
+}
+
+// adminResponse admin 接口响应，各种响应字段放到一起
+type adminResponse struct {
+	Sessions []int64         `json:"sessions,omitempty"`
+	Handles  []int64         `json:"handles,omitempty"`
+	Info     json.RawMessage `json:"info,omitempty"`
+	Level    int             `json:"level,omitempty"`
+	Status   *AdminStatus    `json:"status,omitempty"`
+	Data     struct {
+		Plugins []string `json:"plugins,omitempty"`
+	} `json:"data"`
+}
+
+func (adm *Admin) request(req *janusRequest) (result *adminResponse, err error) {
+	var resp *JanusResponse
+	req.AdminSecret = adm.secret
+	req.admin = true
+	if resp, err = adm.js.requestWait(req); err != nil {
+		err = fmt.Errorf("janus admin %s fail:%w", req.Janus, err)
+		return
+	}
+	if err = resp.HasError(req.Janus); err != nil {
+		return
+	}
+	result = new(adminResponse)
+	if err = json.Unmarshal(resp.oriMsg, result); err != nil {
+		err = fmt.Errorf("janus admin %s decode fail:%w", req.Janus, err)
+		result = nil
+	}
+	return
+}
+
+// ListSessions 列出服务器上所有会话
+func (adm *Admin) ListSessions() (sessions []int64, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "list_sessions"}); err == nil {
+		sessions = result.Sessions
+	}
+	return
+}
+
+// ListHandles 列出会话中的所有 handle
+func (adm *Admin) ListHandles(sessionID int64) (handles []int64, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "list_handles", SessionID: sessionID}); err == nil {
+		handles = result.Handles
+	}
+	return
+}
+
+// HandleInfo 获取 handle 详情，包括 ICE/DTLS 状态
+func (adm *Admin) HandleInfo(sessionID, handleID int64) (info *AdminHandleInfo, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "handle_info", SessionID: sessionID, HandleID: handleID}); err != nil {
+		return
+	}
+	info = new(AdminHandleInfo)
+	if err = json.Unmarshal(result.Info, info); err != nil {
+		info = nil
+		err = fmt.Errorf("janus admin handle_info decode fail:%w", err)
+		return
+	}
+	info.Raw = result.Info
+	return
+}
+
+// SetLogLevel 设置服务器日志级别(0-7)，返回设置后的级别
+func (adm *Admin) SetLogLevel(level int) (current int, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "set_log_level", Level: client.Int(level)}); err == nil {
+		current = result.Level
+	}
+	return
+}
+
+// GetStatus 获取服务器运行参数
+func (adm *Admin) GetStatus() (status *AdminStatus, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "get_status"}); err == nil {
+		if status = result.Status; status == nil {
+			err = fmt.Errorf("janus admin get_status without status")
+		}
+	}
+	return
+}
+
+// AddToken 添加访问 token，plugins 为空时允许访问所有插件，返回 token 可访问的插件
+func (adm *Admin) AddToken(token string, plugins ...string) (allowed []string, err error) {
+	var result *adminResponse
+	if result, err = adm.request(&janusRequest{Janus: "add_token", Token: token, Plugins: plugins}); err == nil {
+		allowed = result.Data.Plugins
+	}
+	return
+}
+
+// RemoveToken 删除访问 token
+func (adm *Admin) RemoveToken(token string) (err error) {
+	_, err = adm.request(&janusRequest{Janus: "remove_token", Token: token})
+	return
+}
+
+// AcceptNewSessions 设置服务器是否接受新会话
+func (adm *Admin) AcceptNewSessions(accept bool) (err error) {
+	_, err = adm.request(&janusRequest{Janus: "accept_new_sessions", Accept: client.Bool(accept)})
+	return
+}
@@ -0,0 +1,53 @@
+package webrtc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAdminTokensWithoutAdminSecret(t *testing.T) {
+	srv := newTestServer(t)
+	adm, err := NewClient(srv.URL(), "", WithLogger(NopLogger())).NewAdmin(srv.URL(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adm.Close()
+	allowed, err := adm.AddToken("t1", PluginVideoRoom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(allowed, []string{PluginVideoRoom}) {
+		t.Fatalf("allowed %v, want [%s]", allowed, PluginVideoRoom)
+	}
+	if _, err = adm.AddToken("t2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range srv.Requests() {
+		if req.Janus == "add_token" && req.Token == "" {
+			t.Fatal("add_token sent without token")
+		}
+	}
+	connect := func(token string) error {
+		js, err := NewClient(srv.URL(), "", WithLogger(NopLogger()), WithAuth(NewTokenAuth(token))).NewJanus()
+		if err == nil {
+			js.Destroy()
+		}
+		return err
+	}
+	if err = connect("t1"); err != nil {
+		t.Fatalf("added token rejected: %v", err)
+	}
+	if err = adm.RemoveToken("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = connect("t1"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("removed token got %v, want ErrUnauthorized", err)
+	}
+	if err = connect("t2"); err != nil {
+		t.Fatalf("remaining token rejected: %v", err)
+	}
+	if err = adm.RemoveToken("t1"); err == nil {
+		t.Fatal("removing a missing token should fail")
+	}
+}
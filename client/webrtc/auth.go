@@ -0,0 +1,101 @@
+package webrtc
+
+import (
+	"context"
+	"sync"
+)
+
+// Credentials 请求认证信息，janus 开启 token_auth 时使用 Token，否则使用 APISecret
+type Credentials struct {
+	APISecret string
+	Token     string
+}
+
+// AuthProvider 提供请求认证信息，每次请求时调用，sessionID 为 0 表示创建会话前的请求
+type AuthProvider interface {
+	Credentials(ctx context.Context, sessionID int64) (Credentials, error)
+}
+
+// AuthFunc 函数形式的 AuthProvider
+type AuthFunc func(ctx context.Context, sessionID int64) (Credentials, error)
+
+// Credentials implements AuthProvider
+func (f AuthFunc) Credentials(ctx context.Context, sessionID int64) (Credentials, error) {
+	return f(ctx, sessionID)
+}
+
+// StaticSecret 固定的 apisecret 认证
+func StaticSecret(secret string) AuthProvider {
+	return AuthFunc(func(context.Context, int64) (Credentials, error) {
+		return Credentials{APISecret: secret}, nil
+	})
+}
+
+// TokenAuth stored-token 认证，可在运行时通过 SetToken 更新 token
+type TokenAuth struct {
+	lock      sync.RWMutex
+	token     string
+	apiSecret string
+}
+
+// NewTokenAuth 创建 token 认证，apiSecret 可选，服务器同时开启 apisecret 时使用
+func NewTokenAuth(token string, apiSecret ...string) *TokenAuth {
+	ta := &TokenAuth{token: token}
+	if len(apiSecret) > 0 {
+		ta.apiSecret = apiSecret[0]
+	}
+	return ta
+}
+
+// SetToken 更新 token，之后的请求使用新 token
+func (ta *TokenAuth) SetToken(token string) {
+	ta.lock.Lock()
+	ta.token = token
+	ta.lock.Unlock()
+}
+
+// Credentials implements AuthProvider
+func (ta *TokenAuth) Credentials(context.Context, int64) (cred Credentials, err error) {
+	ta.lock.RLock()
+	cred.Token = ta.token
+	cred.APISecret = ta.apiSecret
+	ta.lock.RUnlock()
+	return
+}
+
+// SetAuth 设置认证方式，替代 Secret
+func (cli *Client) SetAuth(p AuthProvider) *Client {
+	if p != nil {
+		cli.auth = p
+	}
+	return cli
+}
+
+// WithAuth 认证方式，同 SetAuth
+func WithAuth(p AuthProvider) ClientOption {
+	return func(cli *Client) {
+		cli.SetAuth(p)
+	}
+}
+
+// credentials 未设置认证方式时使用 Secret
+func (cli *Client) credentials(ctx context.Context, sessionID int64) (Credentials, error) {
+	if cli.auth != nil {
+		return cli.auth.Credentials(ctx, sessionID)
+	}
+	return Credentials{APISecret: cli.Secret}, nil
+}
+
+// authorize 填充请求的认证字段，admin 请求使用 admin_secret，其中的 token 为 add_token 等请求的参数，不能覆盖
+func (js *Janus) authorize(ctx context.Context, req *janusRequest) (err error) {
+	var cred Credentials
+	if req.admin {
+		return
+	}
+	if cred, err = js.cli.credentials(ctx, req.SessionID); err != nil {
+		return
+	}
+	req.APISecret = cred.APISecret
+	req.Token = cred.Token
+	return
+}
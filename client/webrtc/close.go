@@ -0,0 +1,140 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloseError Close 时部分 handle 或会话释放失败
+type CloseError struct {
+	Handles map[int64]error // 挂断或解绑失败的 handle
+	Session error           // destroy 请求失败
+	Wait    error           // 等待内部协程结束超时
+}
+
+func (ce *CloseError) Error() string {
+	var fields []string
+	if ce.Session != nil {
+		fields = append(fields, fmt.Sprintf("destroy: %v", ce.Session))
+	}
+	for _, id := range ce.handleIDs() {
+		fields = append(fields, fmt.Sprintf("handle %d: %v", id, ce.Handles[id]))
+	}
+	if ce.Wait != nil {
+		fields = append(fields, fmt.Sprintf("wait: %v", ce.Wait))
+	}
+	return "janus close fail, " + strings.Join(fields, "; ")
+}
+
+// Unwrap 返回第一个错误，用于 errors.Is 判断，如 ErrTimeout
+func (ce *CloseError) Unwrap() error {
+	if ce.Session != nil {
+		return ce.Session
+	}
+	if ids := ce.handleIDs(); len(ids) > 0 {
+		return ce.Handles[ids[0]]
+	}
+	return ce.Wait
+}
+
+func (ce *CloseError) handleIDs() (ids []int64) {
+	for id := range ce.Handles {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+// startReader 启动读消息协程
+func (js *Janus) startReader(tr Transport) {
+	js.wg.Add(1)
+	go func() {
+		defer js.wg.Done()
+		js.consumeEvent(tr)
+	}()
+}
+
+// Close 挂断并解绑所有 handle，发送 destroy 释放服务器会话，断开连接并等待内部协程结束，
+// ctx 有截止时间时 handle 最多使用一半的剩余时间
+// 可重复调用，之后的调用返回第一次的结果，失败时返回 *CloseError
+func (js *Janus) Close(ctx context.Context) error {
+	js.closeOnce.Do(func() {
+		js.closeErr = js.close(ctx)
+	})
+	return js.closeErr
+}
+
+func (js *Janus) close(ctx context.Context) (err error) {
+	var ce = CloseError{Handles: make(map[int64]error)}
+	// 重连中的连接已断开，请求不会有响应，直接释放本地资源
+	if js.State() == SessionStateActive && js.transport() != nil && js.GetSessionID() > 0 {
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		// handle 最多使用一半的剩余时间，保证之后还能发送 destroy
+		var hctx, cancel = ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			hctx, cancel = context.WithTimeout(ctx, time.Until(deadline)/2)
+		}
+		js.handles.Range(func(key interface{}, value interface{}) bool {
+			if h, ok := value.(*Handle); ok {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := h.shutdown(hctx); err != nil {
+						lock.Lock()
+						ce.Handles[h.GetID()] = err
+						lock.Unlock()
+					}
+				}()
+			}
+			return true
+		})
+		wg.Wait()
+		cancel()
+		ce.Session = js.destroySession(ctx)
+	}
+	js.Destroy()
+	done := make(chan struct{})
+	go func() {
+		js.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		ce.Wait = contextError(ctx, "close wait", "")
+	}
+	if ce.Session != nil || ce.Wait != nil || len(ce.Handles) > 0 {
+		js.log(LevelWarn, "session close fail", F(FieldError, &ce))
+		return &ce
+	}
+	js.log(LevelInfo, "session closed")
+	return nil
+}
+
+// shutdown 连接已建立时先挂断，然后解绑
+func (h *Handle) shutdown(ctx context.Context) (err error) {
+	switch h.State() {
+	case HandleStateNegotiating, HandleStateWebRTCUp:
+		if err = h.HangupContext(ctx); err != nil {
+			return
+		}
+	}
+	return h.DetachContext(ctx)
+}
+
+// destroySession 发送 destroy 请求释放服务器上的会话
+func (js *Janus) destroySession(ctx context.Context) (err error) {
+	var req janusRequest
+	var resp *JanusResponse
+	req.Janus = "destroy"
+	req.SessionID = js.GetSessionID()
+	if resp, err = js.requestContext(ctx, &req); err != nil {
+		return
+	}
+	return resp.HasError("destroy")
+}
@@ -0,0 +1,5 @@
+// Package webrtc janus WebRTC 网关客户端，支持 websocket 和 http 传输、会话重连、videoroom 和 sip 插件
+//
+// 包本身只需要 go.mod 声明的 Go 1.17；SlogLogger 适配 log/slog，需要 Go 1.21 及以上编译，
+// 低版本编译时没有这个函数，可以使用 DefaultLogger 或自己实现 Logger 接口
+package webrtc
@@ -0,0 +1,372 @@
+package webrtc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	pion "github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// DefaultDtmfDuration 每个 DTMF 按键的默认时长
+const DefaultDtmfDuration = 160 * time.Millisecond
+
+// MimeTypeTelephoneEvent RFC 4733 telephone-event 编码
+const MimeTypeTelephoneEvent = "audio/telephone-event"
+
+// dtmf RTP 参数，每 20ms 一个包
+const (
+	dtmfPacketTime = 20 * time.Millisecond
+	dtmfInterDigit = 50 * time.Millisecond
+	dtmfVolume     = 10
+	dtmfEndRepeats = 3
+	dtmfMTU        = 1200
+)
+
+// DtmfWriter 在音频 RTP 流中发送 RFC 4733 telephone-event，见 AudioTrack
+type DtmfWriter interface {
+	WriteDtmf(ctx context.Context, digits string, duration time.Duration) error
+}
+
+// SipDtmfInfo sip 插件 dtmf_info 请求
+type SipDtmfInfo struct {
+	Request  string `json:"request"`
+	Digit    string `json:"digit"`
+	Duration int    `json:"duration,omitempty"` // 毫秒
+}
+
+// RegisterDtmfCodec 在 MediaEngine 中注册 telephone-event，clockRate 需与音频编码相同，如 opus 为 48000
+func RegisterDtmfCodec(m *pion.MediaEngine, clockRate uint32, pt pion.PayloadType) error {
+	return m.RegisterCodec(pion.RTPCodecParameters{
+		RTPCodecCapability: pion.RTPCodecCapability{MimeType: MimeTypeTelephoneEvent, ClockRate: clockRate, SDPFmtpLine: "0-16"},
+		PayloadType:        pt,
+	}, pion.RTPCodecTypeAudio)
+}
+
+// SetDtmfTrack 设置非 SIP handle 发送 DTMF 使用的音轨，一般为发布的 AudioTrack
+func (h *Handle) SetDtmfTrack(w DtmfWriter) *Handle {
+	h.stateLock.Lock()
+	h.dtmfTrack = w
+	h.stateLock.Unlock()
+	return h
+}
+
+// dtmfEvent RFC 4733 事件码，0-9 为 0-9，* 为 10，# 为 11，A-D 为 12-15
+func dtmfEvent(digit rune) (code byte, ok bool) {
+	switch {
+	case digit >= '0' && digit <= '9':
+		return byte(digit - '0'), true
+	case digit == '*':
+		return 10, true
+	case digit == '#':
+		return 11, true
+	case digit >= 'A' && digit <= 'D':
+		return byte(digit-'A') + 12, true
+	}
+	return 0, false
+}
+
+func validDtmf(digits string) (err error) {
+	if digits == "" {
+		return fmt.Errorf("empty dtmf digits")
+	}
+	for _, d := range strings.ToUpper(digits) {
+		if _, ok := dtmfEvent(d); !ok {
+			return fmt.Errorf("invalid dtmf digit %q", d)
+		}
+	}
+	return
+}
+
+// sipDtmf 每个按键发送一个 dtmf_info 请求
+func (h *Handle) sipDtmf(ctx context.Context, digits string, duration time.Duration) (err error) {
+	for _, d := range strings.ToUpper(digits) {
+		req := SipDtmfInfo{Request: "dtmf_info", Digit: string(d), Duration: int(duration / time.Millisecond)}
+		rctx, cancel := context.WithTimeout(ctx, DefaultRequestTimeout)
+		_, err = h.SendContext(rctx, &req, nil)
+		cancel()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// rtpDtmf 通过 SetDtmfTrack 设置的音轨发送
+func (h *Handle) rtpDtmf(ctx context.Context, digits string, duration time.Duration) (err error) {
+	h.stateLock.Lock()
+	w := h.dtmfTrack
+	h.stateLock.Unlock()
+	if w == nil {
+		return fmt.Errorf("handle %s dtmf needs an audio track, see SetDtmfTrack", h.GetTag())
+	}
+	return w.WriteDtmf(ctx, digits, duration)
+}
+
+// sleepContext 等待 d 或 ctx 结束
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AudioTrack 发送音频的 pion TrackLocal，可代替 TrackLocalStaticSample/TrackLocalStaticRTP
+// DTMF 按 RFC 4733 在同一 RTP 流中发送，使用音频的 SSRC、序号和时间戳，payload type 为协商的 telephone-event，
+// 发送 DTMF 期间丢弃音频包；需通过 RegisterDtmfCodec 注册与音频相同时钟的 telephone-event
+type AudioTrack struct {
+	codec      pion.RTPCodecCapability
+	id         string
+	streamID   string
+	lock       sync.Mutex
+	bindings   []audioBinding
+	packetizer rtp.Packetizer
+	clockRate  uint32
+	seq        uint16
+	lastTS     uint32    // 最近一个音频包的时间戳
+	lastAt     time.Time // 最近一个音频包的发送时间
+	inEvent    bool
+	sending    chan struct{} // 同时只发送一组 DTMF
+}
+
+type audioBinding struct {
+	id      string
+	ssrc    pion.SSRC
+	pt      pion.PayloadType
+	dtmfPT  pion.PayloadType
+	hasDtmf bool
+	writer  pion.TrackLocalWriter
+}
+
+// NewAudioTrack 创建音轨，codec 为 opus、PCMU、PCMA 或 G722
+func NewAudioTrack(codec pion.RTPCodecCapability, id, streamID string) *AudioTrack {
+	var seed [6]byte
+	rand.Read(seed[:])
+	return &AudioTrack{
+		codec:    codec,
+		id:       id,
+		streamID: streamID,
+		seq:      binary.BigEndian.Uint16(seed[:2]),
+		lastTS:   binary.BigEndian.Uint32(seed[2:]),
+		lastAt:   time.Now(),
+		sending:  make(chan struct{}, 1),
+	}
+}
+
+// Bind implements pion.TrackLocal
+func (t *AudioTrack) Bind(ctx pion.TrackLocalContext) (codec pion.RTPCodecParameters, err error) {
+	var b = audioBinding{id: ctx.ID(), ssrc: ctx.SSRC(), writer: ctx.WriteStream()}
+	var found bool
+	for _, c := range ctx.CodecParameters() {
+		if !found && strings.EqualFold(c.MimeType, t.codec.MimeType) && (t.codec.ClockRate == 0 || c.ClockRate == t.codec.ClockRate) {
+			codec, found = c, true
+		}
+	}
+	if !found {
+		return codec, pion.ErrUnsupportedCodec
+	}
+	for _, c := range ctx.CodecParameters() {
+		if strings.EqualFold(c.MimeType, MimeTypeTelephoneEvent) && c.ClockRate == codec.ClockRate {
+			b.dtmfPT, b.hasDtmf = c.PayloadType, true
+			break
+		}
+	}
+	b.pt = codec.PayloadType
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.packetizer == nil {
+		var payloader rtp.Payloader
+		switch strings.ToLower(codec.MimeType) {
+		case strings.ToLower(pion.MimeTypeOpus):
+			payloader = &codecs.OpusPayloader{}
+		case strings.ToLower(pion.MimeTypePCMU), strings.ToLower(pion.MimeTypePCMA):
+			payloader = &codecs.G711Payloader{}
+		case strings.ToLower(pion.MimeTypeG722):
+			payloader = &codecs.G722Payloader{}
+		default:
+			return codec, pion.ErrNoPayloaderForCodec
+		}
+		t.clockRate = codec.ClockRate
+		t.packetizer = rtp.NewPacketizer(dtmfMTU, 0, 0, payloader, rtp.NewRandomSequencer(), codec.ClockRate)
+	}
+	t.bindings = append(t.bindings, b)
+	return
+}
+
+// Unbind implements pion.TrackLocal
+func (t *AudioTrack) Unbind(ctx pion.TrackLocalContext) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i := range t.bindings {
+		if t.bindings[i].id == ctx.ID() {
+			t.bindings = append(t.bindings[:i], t.bindings[i+1:]...)
+			return nil
+		}
+	}
+	return pion.ErrUnbindFailed
+}
+
+// ID implements pion.TrackLocal
+func (t *AudioTrack) ID() string { return t.id }
+
+// StreamID implements pion.TrackLocal
+func (t *AudioTrack) StreamID() string { return t.streamID }
+
+// Kind implements pion.TrackLocal
+func (t *AudioTrack) Kind() pion.RTPCodecType { return pion.RTPCodecTypeAudio }
+
+// Codec 音频编码
+func (t *AudioTrack) Codec() pion.RTPCodecCapability { return t.codec }
+
+// WriteSample 打包并发送一个音频帧，未协商完成时丢弃
+func (t *AudioTrack) WriteSample(sample media.Sample) (err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.packetizer == nil {
+		return
+	}
+	samples := uint32(sample.Duration.Seconds() * float64(t.clockRate))
+	for _, p := range t.packetizer.Packetize(sample.Data, samples) {
+		if e := t.writeAudio(p); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// WriteRTP 发送一个音频 RTP 包，SSRC、payload type 和序号按协商结果改写
+func (t *AudioTrack) WriteRTP(p *rtp.Packet) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.writeAudio(p)
+}
+
+// writeAudio 记录音频时间戳，发送 DTMF 期间丢弃，需持有锁
+func (t *AudioTrack) writeAudio(p *rtp.Packet) (err error) {
+	t.lastTS, t.lastAt = p.Timestamp, time.Now()
+	if t.inEvent {
+		return
+	}
+	header := p.Header
+	for _, b := range t.bindings {
+		header.SSRC, header.PayloadType = uint32(b.ssrc), uint8(b.pt)
+		err = t.write(b, &header, p.Payload)
+	}
+	t.seq++
+	return
+}
+
+// write 使用音频流的序号发送，需持有锁
+func (t *AudioTrack) write(b audioBinding, header *rtp.Header, payload []byte) (err error) {
+	header.SequenceNumber = t.seq
+	_, err = b.writer.WriteRTP(header, payload)
+	return
+}
+
+// WriteDtmf 按 RFC 4733 发送按键，每个按键的包使用相同时间戳，结束包重复发送 3 次；
+// ctx 结束时发送当前按键的结束包后返回
+func (t *AudioTrack) WriteDtmf(ctx context.Context, digits string, duration time.Duration) (err error) {
+	if err = validDtmf(digits); err != nil {
+		return
+	}
+	select {
+	case t.sending <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-t.sending }()
+	for i, d := range strings.ToUpper(digits) {
+		code, _ := dtmfEvent(d)
+		if err = t.sendEvent(ctx, code, duration); err != nil {
+			return
+		}
+		if i < len(digits)-1 {
+			if err = sleepContext(ctx, dtmfInterDigit); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// sendEvent 发送一个按键，时间戳为按键开始时音频流的时间戳
+func (t *AudioTrack) sendEvent(ctx context.Context, code byte, duration time.Duration) (err error) {
+	t.lock.Lock()
+	if !t.dtmfReady() {
+		t.lock.Unlock()
+		return fmt.Errorf("audio track %s has no negotiated %s", t.id, MimeTypeTelephoneEvent)
+	}
+	samples := uint32(t.clockRate) * uint32(dtmfPacketTime/time.Millisecond) / 1000
+	ts := t.lastTS + uint32(time.Since(t.lastAt).Seconds()*float64(t.clockRate))
+	t.inEvent = true
+	t.lock.Unlock()
+	defer func() {
+		t.lock.Lock()
+		t.inEvent = false
+		t.lock.Unlock()
+	}()
+	packets := uint32(duration / dtmfPacketTime)
+	if packets < 1 {
+		packets = 1
+	}
+	if packets*samples > 0xffff {
+		// duration 字段为 16 位
+		packets = 0xffff / samples
+	}
+	for i := uint32(1); i <= packets; i++ {
+		if err = t.writeEvent(code, ts, i == 1, false, samples*i); err != nil {
+			return
+		}
+		if i < packets {
+			if err = sleepContext(ctx, dtmfPacketTime); err != nil {
+				packets = i
+				break
+			}
+		}
+	}
+	for i := 0; i < dtmfEndRepeats; i++ {
+		if e := t.writeEvent(code, ts, false, true, samples*packets); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// dtmfReady 是否已协商 telephone-event，需持有锁
+func (t *AudioTrack) dtmfReady() bool {
+	for _, b := range t.bindings {
+		if b.hasDtmf {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *AudioTrack) writeEvent(code byte, ts uint32, marker, end bool, duration uint32) (err error) {
+	payload := []byte{code, dtmfVolume, byte(duration >> 8), byte(duration)}
+	if end {
+		payload[1] |= 0x80
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, b := range t.bindings {
+		if !b.hasDtmf {
+			continue
+		}
+		header := rtp.Header{Version: 2, Marker: marker, PayloadType: uint8(b.dtmfPT), SSRC: uint32(b.ssrc), Timestamp: ts}
+		err = t.write(b, &header, payload)
+	}
+	t.seq++
+	return
+}
@@ -0,0 +1,209 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	pion "github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+const testDtmfPT = 101
+
+// dtmfLoopback 两个本地 PeerConnection，发送端使用 AudioTrack，接收端收集收到的 RTP 包
+type dtmfLoopback struct {
+	track   *AudioTrack
+	lock    sync.Mutex
+	packets []*rtp.Packet
+	stop    chan struct{}
+}
+
+func newDtmfLoopback(t *testing.T) (lb *dtmfLoopback) {
+	t.Helper()
+	lb = &dtmfLoopback{stop: make(chan struct{})}
+	newPC := func() *pion.PeerConnection {
+		m := &pion.MediaEngine{}
+		if err := m.RegisterDefaultCodecs(); err != nil {
+			t.Fatal(err)
+		}
+		if err := RegisterDtmfCodec(m, 48000, testDtmfPT); err != nil {
+			t.Fatal(err)
+		}
+		pc, err := pion.NewAPI(pion.WithMediaEngine(m)).NewPeerConnection(pion.Configuration{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { pc.Close() })
+		return pc
+	}
+	sender, receiver := newPC(), newPC()
+	lb.track = NewAudioTrack(pion.RTPCodecCapability{MimeType: pion.MimeTypeOpus, ClockRate: 48000, Channels: 2}, "audio", "dtmf")
+	if _, err := sender.AddTrack(lb.track); err != nil {
+		t.Fatal(err)
+	}
+	receiver.OnTrack(func(track *pion.TrackRemote, _ *pion.RTPReceiver) {
+		for {
+			p, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			lb.lock.Lock()
+			lb.packets = append(lb.packets, p)
+			lb.lock.Unlock()
+		}
+	})
+	negotiate := func(pc *pion.PeerConnection, sdp pion.SessionDescription) {
+		gathered := pion.GatheringCompletePromise(pc)
+		if err := pc.SetLocalDescription(sdp); err != nil {
+			t.Fatal(err)
+		}
+		<-gathered
+	}
+	offer, err := sender.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	negotiate(sender, offer)
+	if err = receiver.SetRemoteDescription(*sender.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	answer, err := receiver.CreateAnswer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	negotiate(receiver, answer)
+	if err = sender.SetRemoteDescription(*receiver.LocalDescription()); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				lb.track.WriteSample(media.Sample{Data: []byte{0xf8, 0xff, 0xfe}, Duration: 20 * time.Millisecond})
+			case <-lb.stop:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(lb.stop) })
+	lb.waitFor(t, func(packets []*rtp.Packet) bool { return len(packets) >= 5 })
+	return
+}
+
+func (lb *dtmfLoopback) waitFor(t *testing.T, cond func([]*rtp.Packet) bool) []*rtp.Packet {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		lb.lock.Lock()
+		packets := append([]*rtp.Packet(nil), lb.packets...)
+		lb.lock.Unlock()
+		if cond(packets) {
+			return packets
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timeout waiting for rtp packets")
+	return nil
+}
+
+func dtmfEnds(packets []*rtp.Packet) (n int) {
+	for _, p := range packets {
+		if p.PayloadType == testDtmfPT && p.Payload[1]&0x80 != 0 {
+			n++
+		}
+	}
+	return
+}
+
+func TestAudioTrackDtmfInAudioStream(t *testing.T) {
+	lb := newDtmfLoopback(t)
+	if err := lb.track.WriteDtmf(context.Background(), "1#", 60*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	packets := lb.waitFor(t, func(packets []*rtp.Packet) bool { return dtmfEnds(packets) >= 2*dtmfEndRepeats })
+	var audioSSRC uint32
+	var digits []byte
+	var eventTS = map[byte]uint32{}
+	for i, p := range packets {
+		if p.PayloadType != testDtmfPT {
+			audioSSRC = p.SSRC
+			continue
+		}
+		if p.SSRC != audioSSRC {
+			t.Fatalf("dtmf ssrc %d, audio ssrc %d", p.SSRC, audioSSRC)
+		}
+		code := p.Payload[0]
+		if ts, ok := eventTS[code]; !ok {
+			if !p.Marker {
+				t.Fatalf("first packet of digit %d without marker", code)
+			}
+			eventTS[code] = p.Timestamp
+			digits = append(digits, code)
+		} else if ts != p.Timestamp {
+			t.Fatalf("digit %d timestamp changed %d -> %d", code, ts, p.Timestamp)
+		}
+		if i > 0 && p.SequenceNumber != packets[i-1].SequenceNumber+1 {
+			t.Fatalf("sequence gap %d -> %d", packets[i-1].SequenceNumber, p.SequenceNumber)
+		}
+	}
+	if string(digits) != "\x01\x0b" {
+		t.Fatalf("digits %v, want [1 11]", digits)
+	}
+	if eventTS[11] <= eventTS[1] {
+		t.Fatalf("second digit timestamp %d not after first %d", eventTS[11], eventTS[1])
+	}
+}
+
+func TestAudioTrackDtmfCanceled(t *testing.T) {
+	lb := newDtmfLoopback(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := lb.track.WriteDtmf(ctx, "5", 2*time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("canceled dtmf returned after %v", d)
+	}
+	// 取消时仍发送结束包，之后音频恢复
+	lb.waitFor(t, func(packets []*rtp.Packet) bool {
+		return dtmfEnds(packets) == dtmfEndRepeats && packets[len(packets)-1].PayloadType != testDtmfPT
+	})
+	// 发送中的 DTMF 不阻塞音频写入
+	if err = lb.track.WriteDtmf(context.Background(), "0", 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAudioTrackDtmfNotNegotiated(t *testing.T) {
+	track := NewAudioTrack(pion.RTPCodecCapability{MimeType: pion.MimeTypeOpus, ClockRate: 48000}, "audio", "dtmf")
+	if err := track.WriteDtmf(context.Background(), "1", 0); err == nil {
+		t.Fatal("dtmf without telephone-event should fail")
+	}
+	if err := track.WriteDtmf(context.Background(), "X", 0); err == nil {
+		t.Fatal("invalid digit should fail")
+	}
+}
+
+func TestSipDtmfInfo(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginSIP, "sip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := srv.CountRequests("message")
+	if err = h.DtmfContext(context.Background(), "12#"); err == nil {
+		t.Fatal("dtmf_info without call should fail")
+	}
+	if n := srv.CountRequests("message") - before; n != 1 {
+		t.Fatalf("sent %d requests after first failure, want 1", n)
+	}
+}
@@ -0,0 +1,198 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// 默认等待时间，用于不带 context 的接口
+var (
+	DefaultRequestTimeout = 5 * time.Second  // 等待同步响应或 ack
+	DefaultEventTimeout   = 15 * time.Second // 收到 ack 后等待异步事件
+)
+
+// janus 核心错误码
+const (
+	ErrCodeUnknown            = -1  // 没有错误码，如请求被取消
+	ErrCodeUnauthorized       = 403 // 认证失败
+	ErrCodeUnauthorizedPlugin = 405 // 无权使用插件
+	ErrCodeTransport          = 450 // 传输错误，客户端连接断开也使用该错误码
+	ErrCodeSessionNotFound    = 458 // 会话不存在
+	ErrCodeHandleNotFound     = 459 // handle 不存在
+	ErrCodePluginNotFound     = 460 // 插件不存在
+	ErrCodeTimeout            = 490 // 超时，客户端等待响应超时也使用该错误码
+)
+
+// videoroom 插件错误码
+const (
+	ErrCodeVideoRoomAlreadyJoined  = 425 // 已加入房间
+	ErrCodeVideoRoomNoSuchRoom     = 426 // 房间不存在
+	ErrCodeVideoRoomRoomExists     = 427 // 房间已存在
+	ErrCodeVideoRoomNoSuchFeed     = 428 // 发布者不存在
+	ErrCodeVideoRoomInvalidElement = 430 // 参数错误
+	ErrCodeVideoRoomUnauthorized   = 433 // 房间密码或 pin 错误
+)
+
+// sip 插件错误码
+const (
+	ErrCodeSIPAlreadyRegistered = 445 // 已注册
+	ErrCodeSIPWrongState        = 447 // 当前状态不允许该请求
+	ErrCodeSIPNoSuchCallID      = 454 // 呼叫不存在
+)
+
+// 哨兵错误，可用 errors.Is 判断 janus 或插件返回的错误，也实现了 Error 接口
+var (
+	// ErrUnauthorized apisecret 或 token 认证失败(janus 错误码 403)，token 过期时可刷新后重试
+	ErrUnauthorized = newCodeError(ErrCodeUnauthorized, "unauthorized", errorKey{code: ErrCodeUnauthorized})
+	// ErrPluginUnauthorized token 没有使用该插件的权限(janus 错误码 405)，刷新 token 不能解决，需服务器授权插件
+	ErrPluginUnauthorized = newCodeError(ErrCodeUnauthorizedPlugin, "unauthorized plugin", errorKey{code: ErrCodeUnauthorizedPlugin})
+	// ErrRoomUnauthorized 房间 pin 或 secret 错误(videoroom 错误码 433)，与会话认证无关
+	ErrRoomUnauthorized = newCodeError(ErrCodeVideoRoomUnauthorized, "room unauthorized", errorKey{PluginVideoRoom, ErrCodeVideoRoomUnauthorized})
+	// ErrTimeout 请求等待响应超时(janus 错误码 490)
+	ErrTimeout = newCodeError(ErrCodeTimeout, "request timeout", errorKey{code: ErrCodeTimeout})
+	// ErrNotConnected 没有到服务器的连接或连接已断开，见 ConnError
+	ErrNotConnected = newCodeError(ErrCodeTransport, "not connected")
+	// ErrTransactionClosed Transaction 已关闭，不再接收事件
+	ErrTransactionClosed = newCodeError(ErrCodeUnknown, "transaction closed")
+	ErrSessionNotFound   = newCodeError(ErrCodeSessionNotFound, "session not found", errorKey{code: ErrCodeSessionNotFound})
+	ErrHandleNotFound    = newCodeError(ErrCodeHandleNotFound, "handle not found", errorKey{code: ErrCodeHandleNotFound})
+	ErrPluginNotFound    = newCodeError(ErrCodePluginNotFound, "plugin not found", errorKey{code: ErrCodePluginNotFound})
+	ErrRoomNotFound      = newCodeError(ErrCodeVideoRoomNoSuchRoom, "room not found", errorKey{PluginVideoRoom, ErrCodeVideoRoomNoSuchRoom})
+	ErrRoomExists        = newCodeError(ErrCodeVideoRoomRoomExists, "room already exists", errorKey{PluginVideoRoom, ErrCodeVideoRoomRoomExists})
+	ErrAlreadyJoined     = newCodeError(ErrCodeVideoRoomAlreadyJoined, "already joined", errorKey{PluginVideoRoom, ErrCodeVideoRoomAlreadyJoined})
+	// ErrInvalidRoomOption 会议室参数错误，发送前校验失败或插件返回 430
+	ErrInvalidRoomOption = newCodeError(ErrCodeVideoRoomInvalidElement, "invalid room option", errorKey{PluginVideoRoom, ErrCodeVideoRoomInvalidElement})
+	ErrNoSuchFeed        = newCodeError(ErrCodeVideoRoomNoSuchFeed, "no such feed", errorKey{PluginVideoRoom, ErrCodeVideoRoomNoSuchFeed})
+	ErrAlreadyRegistered = newCodeError(ErrCodeSIPAlreadyRegistered, "already registered", errorKey{PluginSIP, ErrCodeSIPAlreadyRegistered})
+	ErrSIPWrongState     = newCodeError(ErrCodeSIPWrongState, "wrong sip state", errorKey{PluginSIP, ErrCodeSIPWrongState})
+	ErrNoSuchCall        = newCodeError(ErrCodeSIPNoSuchCallID, "no such call", errorKey{PluginSIP, ErrCodeSIPNoSuchCallID})
+	// ErrNoHealthyServer 连接池中没有可用的服务器
+	ErrNoHealthyServer = newCodeError(ErrCodeTransport, "janus pool has no healthy server")
+)
+
+// errorKey 错误来源和错误码，plugin 为空表示 janus 核心错误
+type errorKey struct {
+	plugin string
+	code   int
+}
+
+// codeError 哨兵错误，keys 为对应的 janus 或插件错误
+type codeError struct {
+	code   int
+	reason string
+	keys   []errorKey
+}
+
+func newCodeError(code int, reason string, keys ...errorKey) error {
+	return &codeError{code: code, reason: reason, keys: keys}
+}
+
+func (ce *codeError) Error() string {
+	return "janus " + ce.reason
+}
+
+func (ce *codeError) Code() int {
+	return ce.code
+}
+
+func (ce *codeError) Reason() string {
+	return ce.reason
+}
+
+// match 判断 plugin 返回的错误码是否对应该错误
+func (ce *codeError) match(plugin string, code int) bool {
+	for _, key := range ce.keys {
+		if key.plugin == plugin && key.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeoutError 请求超时错误，记录超时的请求和 transaction
+type TimeoutError struct {
+	Request     string
+	Transaction string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("janus %s timeout with transaction %s", e.Request, e.Transaction)
+}
+
+func (e *TimeoutError) Code() int {
+	return ErrCodeTimeout
+}
+
+func (e *TimeoutError) Reason() string {
+	return "timeout"
+}
+
+// Is 支持 errors.Is(err, ErrTimeout) 和 errors.Is(err, context.DeadlineExceeded)
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout || target == context.DeadlineExceeded
+}
+
+// ConnError 连接不可用或发送失败，Err 为传输返回的原始错误
+type ConnError struct {
+	Request string
+	Err     error
+}
+
+func (e *ConnError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("janus %s fail: no connection to the server", e.Request)
+	}
+	return fmt.Sprintf("janus %s fail: %v", e.Request, e.Err)
+}
+
+func (e *ConnError) Code() int {
+	return ErrCodeTransport
+}
+
+func (e *ConnError) Reason() string {
+	if e.Err == nil {
+		return "no connection to the server"
+	}
+	return e.Err.Error()
+}
+
+// Is 支持 errors.Is(err, ErrNotConnected)
+func (e *ConnError) Is(target error) bool {
+	return target == ErrNotConnected
+}
+
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// CanceledError 请求等待时 ctx 被取消，Err 为 ctx.Err()
+type CanceledError struct {
+	Request     string
+	Transaction string
+	Err         error
+}
+
+func (e *CanceledError) Error() string {
+	return fmt.Sprintf("janus %s with transaction %s:%v", e.Request, e.Transaction, e.Err)
+}
+
+func (e *CanceledError) Code() int {
+	return ErrCodeUnknown
+}
+
+func (e *CanceledError) Reason() string {
+	return e.Err.Error()
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// contextError 把 context 结束的原因转换为请求错误，超时返回 *TimeoutError，否则返回 *CanceledError
+func contextError(ctx context.Context, request, transaction string) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &TimeoutError{Request: request, Transaction: transaction}
+	}
+	return &CanceledError{Request: request, Transaction: transaction, Err: ctx.Err()}
+}
@@ -0,0 +1,37 @@
+package webrtc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnauthorizedSentinels(t *testing.T) {
+	sentinels := []error{ErrUnauthorized, ErrPluginUnauthorized, ErrRoomUnauthorized}
+	cases := []struct {
+		err  error
+		want error
+	}{
+		{NewError(ErrCodeUnauthorized, "Unauthorized request"), ErrUnauthorized},
+		{NewError(ErrCodeUnauthorizedPlugin, "Unauthorized plugin"), ErrPluginUnauthorized},
+		{NewPluginError(PluginVideoRoom, ErrCodeVideoRoomUnauthorized, "Unauthorized (wrong pin)"), ErrRoomUnauthorized},
+		// 其他插件的 433 不是房间认证错误
+		{NewPluginError(PluginSIP, ErrCodeVideoRoomUnauthorized, "other"), nil},
+	}
+	for _, c := range cases {
+		for _, sentinel := range sentinels {
+			if got := errors.Is(c.err, sentinel); got != (sentinel == c.want) {
+				t.Errorf("errors.Is(%v, %v) = %v", c.err, sentinel, got)
+			}
+		}
+	}
+}
+
+func TestPluginUnauthorizedFromServer(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	srv.FailNext("attach", ErrCodeUnauthorizedPlugin, "Unauthorized (plugin not allowed)")
+	_, err := js.Attach(PluginVideoRoom, "denied")
+	if !errors.Is(err, ErrPluginUnauthorized) || errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("got %v, want only ErrPluginUnauthorized", err)
+	}
+}
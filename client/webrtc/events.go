@@ -0,0 +1,294 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// Event handle 事件，具体类型为 *WebRTCUp, *Hangup, *Media, *SlowLink, *DataReady, *Trickle, *Detached, *PluginMessage
+type Event interface {
+	Name() string    // janus 事件名，如 webrtcup,hangup,event
+	HandleID() int64 // 事件所属 handle
+	Raw() []byte     // 原始消息
+}
+
+type eventHeader struct {
+	name     string
+	handleID int64
+	raw      []byte
+}
+
+func newEventHeader(event *JanusResponse) eventHeader {
+	return eventHeader{name: event.Janus, handleID: event.Sender, raw: event.oriMsg}
+}
+
+// Name janus 事件名
+func (eh eventHeader) Name() string {
+	return eh.name
+}
+
+// HandleID 事件所属 handle
+func (eh eventHeader) HandleID() int64 {
+	return eh.handleID
+}
+
+// Raw 原始消息
+func (eh eventHeader) Raw() []byte {
+	return eh.raw
+}
+
+// WebRTCUp PeerConnection 建立
+type WebRTCUp struct {
+	eventHeader
+}
+
+// Hangup PeerConnection 断开
+type Hangup struct {
+	eventHeader
+	Reason string
+}
+
+// Media janus 开始或停止接收某路媒体
+type Media struct {
+	eventHeader
+	Type      string // audio,video
+	Receiving bool
+}
+
+// SlowLink 链路质量差，丢包较多
+type SlowLink struct {
+	eventHeader
+	Media  string
+	Uplink bool
+	Lost   int
+}
+
+// DataReady 数据通道可用
+type DataReady struct {
+	eventHeader
+}
+
+// Detached handle 已解绑，之后不会再有事件
+type Detached struct {
+	eventHeader
+}
+
+// PluginMessage 插件消息
+type PluginMessage struct {
+	eventHeader
+	Plugin      string
+	Transaction string
+	Data        json.RawMessage
+	Jsep        *Jsep
+	decoded     interface{}
+}
+
+// VideoRoom 视频会议插件消息，其他插件返回 nil
+func (pm *PluginMessage) VideoRoom() *VideoRoomResponse {
+	v, _ := pm.decoded.(*VideoRoomResponse)
+	return v
+}
+
+// SIP sip 插件消息，其他插件返回 nil
+func (pm *PluginMessage) SIP() *SipEvent {
+	v, _ := pm.decoded.(*SipEvent)
+	return v
+}
+
+// Decode 按自定义结构解析插件消息
+func (pm *PluginMessage) Decode(v interface{}) error {
+	return json.Unmarshal(pm.Data, v)
+}
+
+// OverflowPolicy 事件通道满时的处理方式
+type OverflowPolicy int
+
+// overflow policy defined
+const (
+	DropOldest OverflowPolicy = iota // 丢弃最早未读的事件，保留最新事件
+	DropNewest                       // 丢弃新到的事件
+	Block                            // 阻塞等待读取，会阻塞该 handle 后续事件，队列满时还会阻塞会话读协程，影响同一会话的所有 handle
+)
+
+// DefaultEventBuffer 事件通道默认缓存数
+const DefaultEventBuffer = 64
+
+// eventStream 按接收顺序把 handle 事件交给回调和事件通道
+type eventStream struct {
+	inbox   chan *JanusResponse
+	quit    chan struct{}
+	stop    sync.Once
+	events  chan Event
+	policy  OverflowPolicy
+	dropped uint64
+}
+
+func newEventStream(size int, policy OverflowPolicy) *eventStream {
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+	return &eventStream{
+		inbox:  make(chan *JanusResponse, size),
+		quit:   make(chan struct{}),
+		events: make(chan Event, size),
+		policy: policy,
+	}
+}
+
+// Events 返回 handle 的事件通道，事件按服务器发送顺序到达，handle 解绑后通道关闭
+func (h *Handle) Events() <-chan Event {
+	return h.stream.events
+}
+
+// DroppedEvents 事件队列或事件通道满时丢弃的事件数
+func (h *Handle) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&h.stream.dropped)
+}
+
+// SetEventBuffer 设置事件通道缓存数和溢出策略，需在 Attach 之前通过 Janus.SetEventBuffer 设置才对新 handle 生效
+func (js *Janus) SetEventBuffer(size int, policy OverflowPolicy) *Janus {
+	js.trLock.Lock()
+	js.eventBuffer = size
+	js.eventPolicy = policy
+	js.trLock.Unlock()
+	return js
+}
+
+// eventConfig 新 handle 使用的事件通道配置
+func (js *Janus) eventConfig() (size int, policy OverflowPolicy) {
+	js.trLock.RLock()
+	defer js.trLock.RUnlock()
+	return js.eventBuffer, js.eventPolicy
+}
+
+// dispatch 由读消息协程调用，按顺序放入 handle 的事件队列，队列满时按溢出策略丢弃，只有 Block 策略会阻塞读协程
+func (h *Handle) dispatch(event *JanusResponse) {
+	var stream = h.stream
+	if stream.policy == Block {
+		select {
+		case stream.inbox <- event:
+		case <-stream.quit:
+		}
+		return
+	}
+	for {
+		select {
+		case stream.inbox <- event:
+			return
+		case <-stream.quit:
+			return
+		default:
+		}
+		if stream.policy == DropNewest {
+			atomic.AddUint64(&stream.dropped, 1)
+			return
+		}
+		select {
+		case <-stream.inbox:
+			atomic.AddUint64(&stream.dropped, 1)
+		default:
+		}
+	}
+}
+
+// detached 本地解绑成功，交给事件协程发布 Detached 事件后关闭事件通道，ctx 结束时直接关闭
+func (h *Handle) detached(ctx context.Context) {
+	var event = &JanusResponse{Janus: "detached", Sender: h.GetID()}
+	select {
+	case h.stream.inbox <- event:
+	case <-h.stream.quit:
+	case <-ctx.Done():
+		h.closeEvents()
+	}
+}
+
+// eventLoop 每个 handle 一个协程，顺序处理事件
+func (h *Handle) eventLoop() {
+	defer close(h.stream.events)
+	for {
+		select {
+		case event := <-h.stream.inbox:
+			h.processEvent(event)
+		case <-h.stream.quit:
+			return
+		}
+	}
+}
+
+// closeEvents 停止事件处理并关闭事件通道
+func (h *Handle) closeEvents() {
+	h.stream.stop.Do(func() {
+		close(h.stream.quit)
+	})
+}
+
+// callback 调用 SetEventCallBack 设置的回调
+func (h *Handle) callback(name string, data interface{}) {
+	h.stateLock.Lock()
+	f := h.callBack
+	h.stateLock.Unlock()
+	if f != nil {
+		h.runCallback(func() { f(h, name, data) })
+	}
+}
+
+// runCallback 把用户回调交给回调协程按顺序执行，事件协程不等待回调，
+// 回调中调用 Hangup、WaitState 等等待后续事件的接口时不会阻塞事件处理
+func (h *Handle) runCallback(f func()) {
+	h.callLock.Lock()
+	h.callQueue = append(h.callQueue, f)
+	if h.calling {
+		h.callLock.Unlock()
+		return
+	}
+	h.calling = true
+	h.callLock.Unlock()
+	go func() {
+		for {
+			h.callLock.Lock()
+			if len(h.callQueue) == 0 {
+				h.calling = false
+				h.callLock.Unlock()
+				return
+			}
+			f := h.callQueue[0]
+			h.callQueue = h.callQueue[1:]
+			h.callLock.Unlock()
+			f()
+		}
+	}()
+}
+
+// publish 按溢出策略写入事件通道，只在 eventLoop 中调用
+func (h *Handle) publish(ev Event) {
+	var stream = h.stream
+	switch stream.policy {
+	case Block:
+		select {
+		case stream.events <- ev:
+		case <-stream.quit:
+		}
+		return
+	case DropNewest:
+		select {
+		case stream.events <- ev:
+		default:
+			atomic.AddUint64(&stream.dropped, 1)
+		}
+		return
+	}
+	for {
+		select {
+		case stream.events <- ev:
+			return
+		default:
+		}
+		select {
+		case <-stream.events:
+			atomic.AddUint64(&stream.dropped, 1)
+		default:
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package webrtc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client/webrtc/janustest"
+)
+
+// newTestServer 启动模拟服务器，测试结束时在会话释放之后关闭
+func newTestServer(t *testing.T) *janustest.Server {
+	t.Helper()
+	srv := janustest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestJanus 连接模拟服务器创建会话，测试结束时释放
+func newTestJanus(t *testing.T, srv *janustest.Server, opts ...ClientOption) *Janus {
+	t.Helper()
+	opts = append([]ClientOption{WithLogger(NopLogger())}, opts...)
+	js, err := NewClient(srv.URL(), "", opts...).NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		js.Close(ctx)
+		cancel()
+	})
+	return js
+}
+
+func TestEventBufferSizesInbox(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv).SetEventBuffer(3, DropNewest)
+	h, err := js.Attach(PluginVideoRoom, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cap(h.stream.inbox) != 3 || cap(h.stream.events) != 3 {
+		t.Fatalf("inbox %d events %d, want 3", cap(h.stream.inbox), cap(h.stream.events))
+	}
+}
+
+func TestEventsInOrderAndDropNewest(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv).SetEventBuffer(2, DropNewest)
+	h, err := js.Attach(PluginVideoRoom, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := []string{"audio", "video", "audio", "video", "audio"}
+	for _, kind := range kinds {
+		srv.Notify(h.GetID(), "media", map[string]interface{}{"type": kind, "receiving": true})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for h.DroppedEvents() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("dropped %d events, want 3", h.DroppedEvents())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := 0; i < 2; i++ {
+		ev := (<-h.Events()).(*Media)
+		if ev.Type != kinds[i] || ev.HandleID() != h.GetID() {
+			t.Fatalf("event %d is %s from %d, want %s", i, ev.Type, ev.HandleID(), kinds[i])
+		}
+	}
+}
+
+func TestEventsClosedOnDetach(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-h.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events channel not closed after detach")
+		}
+	}
+}
+
+func TestDispatchAppliesOverflowPolicy(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropOldest, DropNewest} {
+		// 没有事件协程读取，队列满后 dispatch 不能阻塞
+		h := &Handle{stream: newEventStream(2, policy)}
+		for i := int64(1); i <= 5; i++ {
+			h.dispatch(&JanusResponse{Janus: "media", Sender: i})
+		}
+		if n := h.DroppedEvents(); n != 3 {
+			t.Fatalf("policy %d dropped %d, want 3", policy, n)
+		}
+		want := []int64{4, 5}
+		if policy == DropNewest {
+			want = []int64{1, 2}
+		}
+		for _, id := range want {
+			if ev := <-h.stream.inbox; ev.Sender != id {
+				t.Fatalf("policy %d kept %d, want %d", policy, ev.Sender, id)
+			}
+		}
+	}
+}
+
+func TestSlowCallbackDoesNotStallSession(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv).SetEventBuffer(1, DropNewest)
+	slow, err := js.Attach(PluginVideoRoom, "slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := js.Attach(PluginVideoRoom, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	defer close(release)
+	slow.SetEventCallBack(func(*Handle, string, interface{}) { <-release })
+	for i := 0; i < 10; i++ {
+		srv.Notify(slow.GetID(), "webrtcup", nil)
+	}
+	srv.Notify(other.GetID(), "webrtcup", nil)
+	select {
+	case <-other.Events():
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow handle stalled the session reader")
+	}
+}
+
+func TestCallbackCanHangup(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(chan error, 1)
+	h.SetEventCallBack(func(h *Handle, name string, _ interface{}) {
+		if name != "webrtcup" {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		err := h.HangupContext(ctx)
+		if err == nil {
+			err = h.WaitState(ctx, HandleStateHungup)
+		}
+		result <- err
+	})
+	srv.Notify(h.GetID(), "webrtcup", nil)
+	select {
+	case err = <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("hangup from callback blocked")
+	}
+}
+
+func TestLocalDetachPublishesDetached(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "detach")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 服务器只回复 success，不发送 detached 事件
+	srv.OnRequest("detach", func(c *janustest.Conn, req *janustest.Request) bool {
+		c.Send(map[string]interface{}{"janus": "success", "session_id": req.SessionID, "transaction": req.Transaction})
+		return true
+	})
+	if err = h.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	var last Event
+	for ev := range h.Events() {
+		last = ev
+	}
+	if _, ok := last.(*Detached); !ok || last.HandleID() != h.GetID() {
+		t.Fatalf("last event %T, want *Detached", last)
+	}
+}
+
+func TestHandleSettersRaceWithEvents(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "race")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidate := map[string]interface{}{"candidate": map[string]interface{}{"sdpMid": "0", "candidate": "candidate:1 1 udp 1 127.0.0.1 5000 typ host"}}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			srv.Notify(h.GetID(), "webrtcup", nil)
+			srv.Notify(h.GetID(), "trickle", candidate)
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	seen := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case seen <- struct{}{}:
+		default:
+		}
+	}
+	// 事件协程读取 tag 和回调的同时修改，go test -race 检查
+	deadline := time.After(5 * time.Second)
+	for n := 0; n < 10; {
+		select {
+		case <-seen:
+			n++
+		case <-deadline:
+			t.Fatalf("got %d callbacks", n)
+		default:
+		}
+		h.SetTag("race")
+		h.SetEventCallBack(func(*Handle, string, interface{}) { notify() })
+		h.OnRemoteCandidate(func(*ICECandidate) { notify() })
+	}
+	if h.GetTag() != "race" {
+		t.Fatalf("tag %s", h.GetTag())
+	}
+}
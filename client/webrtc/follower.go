@@ -0,0 +1,264 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/finove/webrtctest/client"
+	pion "github.com/pion/webrtc/v3"
+)
+
+// TrackSink 处理订阅到的远端轨道，如录制到文件，在单独的协程中调用
+// 发布者离开或停止发布时 PeerConnection 关闭，读取轨道返回错误后应退出
+type TrackSink func(feed RosterParticipant, track *pion.TrackRemote, receiver *pion.RTPReceiver)
+
+// RoomFollower 加入会议室但不发布，为每个发布者创建订阅 handle 和 PeerConnection，把收到的轨道交给 TrackSink
+// 发布者停止发布或离开时释放对应的订阅，订阅过程中发布者离开时放弃订阅
+type RoomFollower struct {
+	js      *Janus
+	room    int64
+	sink    TrackSink
+	display string
+	pin     string
+	config  pion.Configuration
+	onError func(feed int64, err error)
+	pub     *Handle
+	roster  *Roster
+	lock    sync.Mutex
+	feeds   map[int64]*followedFeed
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// followedFeed 一个发布者的订阅
+type followedFeed struct {
+	info   RosterParticipant
+	ctx    context.Context
+	cancel context.CancelFunc
+	ready  bool
+}
+
+// NewRoomFollower 创建会议室订阅者，Start 后开始订阅
+func NewRoomFollower(js *Janus, room int64, sink TrackSink) *RoomFollower {
+	return &RoomFollower{js: js, room: room, sink: sink, display: "follower", feeds: make(map[int64]*followedFeed)}
+}
+
+// SetDisplay 设置在会议室中的显示名
+func (rf *RoomFollower) SetDisplay(display string) *RoomFollower {
+	rf.display = display
+	return rf
+}
+
+// SetPin 设置加入会议室的密码
+func (rf *RoomFollower) SetPin(pin string) *RoomFollower {
+	rf.pin = pin
+	return rf
+}
+
+// SetConfiguration 设置创建 PeerConnection 使用的配置，如 ICE 服务器
+func (rf *RoomFollower) SetConfiguration(config pion.Configuration) *RoomFollower {
+	rf.config = config
+	return rf
+}
+
+// OnError 设置订阅失败回调，发布者已离开导致的失败不回调
+func (rf *RoomFollower) OnError(f func(feed int64, err error)) *RoomFollower {
+	rf.onError = f
+	return rf
+}
+
+// Roster 返回会议室参与者名单，Start 之前为 nil
+func (rf *RoomFollower) Roster() *Roster {
+	return rf.roster
+}
+
+// Start 加入会议室，已在发布的参与者和之后发布的参与者都会被订阅
+func (rf *RoomFollower) Start(ctx context.Context) (err error) {
+	var join VideoRoomJoin
+	if rf.pub, err = rf.js.AttachContext(ctx, PluginVideoRoom, "follower"); err != nil {
+		return
+	}
+	rf.roster = NewRoster(rf.pub).OnChange(rf.onChange)
+	join.Request = "join"
+	join.Ptype = "publisher"
+	join.Room = rf.room
+	join.Display = rf.display
+	join.Pin = rf.pin
+	if _, err = rf.pub.SendContext(ctx, &join, nil); err != nil {
+		err = fmt.Errorf("follower join room %d fail:%w", rf.room, err)
+		rf.pub.DetachContext(ctx)
+	}
+	return
+}
+
+// Feeds 返回已完成订阅的发布者
+func (rf *RoomFollower) Feeds() (feeds []int64) {
+	rf.lock.Lock()
+	for id, feed := range rf.feeds {
+		if feed.ready {
+			feeds = append(feeds, id)
+		}
+	}
+	rf.lock.Unlock()
+	sort.Slice(feeds, func(i, j int) bool { return feeds[i] < feeds[j] })
+	return
+}
+
+// Close 释放所有订阅并离开会议室，等待订阅协程结束
+func (rf *RoomFollower) Close(ctx context.Context) (err error) {
+	rf.lock.Lock()
+	rf.closed = true
+	for id, feed := range rf.feeds {
+		feed.cancel()
+		delete(rf.feeds, id)
+	}
+	rf.lock.Unlock()
+	if rf.pub != nil {
+		err = rf.pub.shutdown(ctx)
+	}
+	done := make(chan struct{})
+	go func() {
+		rf.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = contextError(ctx, "follower close", "")
+		}
+	}
+	return
+}
+
+// onChange 名单变化，在发布者 handle 的事件协程中调用，订阅在单独的协程中进行
+func (rf *RoomFollower) onChange(ev RosterEvent) {
+	switch ev.Change {
+	case RosterPublished:
+		rf.follow(ev.Participant)
+	case RosterUnpublished, RosterLeft:
+		rf.unfollow(ev.Participant.ID)
+	}
+}
+
+func (rf *RoomFollower) follow(p RosterParticipant) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.closed || rf.feeds[p.ID] != nil {
+		return
+	}
+	feed := &followedFeed{info: p}
+	feed.ctx, feed.cancel = context.WithCancel(context.Background())
+	rf.feeds[p.ID] = feed
+	rf.wg.Add(1)
+	go func() {
+		defer rf.wg.Done()
+		rf.subscribe(feed)
+	}()
+}
+
+func (rf *RoomFollower) unfollow(id int64) {
+	rf.lock.Lock()
+	if feed := rf.feeds[id]; feed != nil {
+		feed.cancel()
+		delete(rf.feeds, id)
+	}
+	rf.lock.Unlock()
+}
+
+// subscribe 订阅一个发布者直到发布者离开或 Close，之后释放 handle 和 PeerConnection
+func (rf *RoomFollower) subscribe(feed *followedFeed) {
+	var h *Handle
+	var pc *pion.PeerConnection
+	var err error
+	defer func() {
+		if pc != nil {
+			pc.Close()
+		}
+		if h != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+			h.shutdown(ctx)
+			cancel()
+		}
+		if err != nil && feed.ctx.Err() == nil && !errors.Is(err, ErrNoSuchFeed) && rf.onError != nil {
+			rf.onError(feed.info.ID, err)
+		}
+		// 出错时移除，发布者重新发布时可再次订阅
+		rf.lock.Lock()
+		if rf.feeds[feed.info.ID] == feed {
+			delete(rf.feeds, feed.info.ID)
+		}
+		rf.lock.Unlock()
+	}()
+	if h, pc, err = rf.negotiate(feed); err != nil {
+		rf.js.log(LevelWarn, "follower subscribe fail", F("feed", feed.info.ID), F(FieldError, err))
+		return
+	}
+	rf.lock.Lock()
+	feed.ready = true
+	rf.lock.Unlock()
+	rf.js.log(LevelInfo, "follower subscribed", F("feed", feed.info.ID), F("display", feed.info.Display))
+	<-feed.ctx.Done()
+}
+
+// negotiate 以订阅者加入，用 janus 的 offer 创建 PeerConnection 并发送 answer
+func (rf *RoomFollower) negotiate(feed *followedFeed) (h *Handle, pc *pion.PeerConnection, err error) {
+	var join VideoRoomJoin
+	var resp *JanusResponse
+	var answer pion.SessionDescription
+	// 每个请求单独限时，订阅的 ctx 只在发布者离开或 Close 时结束
+	ctx, cancel := context.WithTimeout(feed.ctx, DefaultRequestTimeout)
+	h, err = rf.js.AttachContext(ctx, PluginVideoRoom, fmt.Sprintf("subscriber-%d", feed.info.ID))
+	cancel()
+	if err != nil {
+		return
+	}
+	join.AsSubscriber(rf.room, feed.info.ID)
+	join.Pin = rf.pin
+	ctx, cancel = context.WithTimeout(feed.ctx, DefaultRequestTimeout+DefaultEventTimeout)
+	resp, err = h.SendContext(ctx, &join, nil)
+	cancel()
+	if err != nil {
+		return
+	}
+	if resp.Jsep.Type != "offer" {
+		err = fmt.Errorf("subscribe feed %d without offer", feed.info.ID)
+		return
+	}
+	if pc, err = pion.NewPeerConnection(rf.config); err != nil {
+		return
+	}
+	pc.OnTrack(func(track *pion.TrackRemote, receiver *pion.RTPReceiver) {
+		if rf.sink != nil {
+			go rf.sink(feed.info, track, receiver)
+		}
+	})
+	if err = pc.SetRemoteDescription(pion.SessionDescription{Type: pion.SDPTypeOffer, SDP: resp.Jsep.SDP}); err != nil {
+		return
+	}
+	if answer, err = pc.CreateAnswer(nil); err != nil {
+		return
+	}
+	gathered := pion.GatheringCompletePromise(pc)
+	if err = pc.SetLocalDescription(answer); err != nil {
+		return
+	}
+	ctx, cancel = context.WithTimeout(feed.ctx, DefaultRequestTimeout)
+	select {
+	case <-gathered:
+	case <-ctx.Done():
+		err = contextError(ctx, "ice gathering", "")
+	}
+	cancel()
+	if err != nil {
+		return
+	}
+	start := VideoRoomCommon{Request: "start", Room: rf.room}
+	ctx, cancel = context.WithTimeout(feed.ctx, DefaultRequestTimeout+DefaultEventTimeout)
+	_, err = h.SendContext(ctx, &start, &Jsep{Type: "answer", SDP: pc.LocalDescription().SDP, Trickle: client.Bool(false)})
+	cancel()
+	return
+}
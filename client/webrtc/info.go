@@ -0,0 +1,149 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ModuleInfo 插件、传输或事件处理模块信息
+type ModuleInfo struct {
+	Name          string `json:"name"`
+	Author        string `json:"author"`
+	Description   string `json:"description"`
+	VersionString string `json:"version_string"`
+	Version       int    `json:"version"`
+}
+
+// ServerInfo info 请求返回的服务器信息
+type ServerInfo struct {
+	Name                  string                `json:"name"`
+	Version               int                   `json:"version"`
+	VersionString         string                `json:"version_string"`
+	Author                string                `json:"author"`
+	CommitHash            string                `json:"commit-hash"`
+	CompileTime           string                `json:"compile-time"`
+	ServerName            string                `json:"server-name"`
+	LocalIP               string                `json:"local-ip"`
+	PublicIP              string                `json:"public-ip"`
+	DataChannels          bool                  `json:"data_channels"`
+	AcceptingNewSessions  bool                  `json:"accepting-new-sessions"`
+	SessionTimeout        int                   `json:"session-timeout"`
+	ReclaimSessionTimeout int                   `json:"reclaim-session-timeout"`
+	CandidatesTimeout     int                   `json:"candidates-timeout"`
+	IPv6                  bool                  `json:"ipv6"`
+	IceLite               bool                  `json:"ice-lite"`
+	IceTCP                bool                  `json:"ice-tcp"`
+	FullTrickle           bool                  `json:"full-trickle"`
+	MDNSEnabled           bool                  `json:"mdns-enabled"`
+	MinNackQueue          int                   `json:"min-nack-queue"`
+	TWCCPeriod            int                   `json:"twcc-period"`
+	DTLSMtu               int                   `json:"dtls-mtu"`
+	StaticEventLoops      int                   `json:"static-event-loops"`
+	APISecret             bool                  `json:"api_secret"`
+	AuthToken             bool                  `json:"auth_token"`
+	EventHandlers         bool                  `json:"event_handlers"`
+	Dependencies          map[string]string     `json:"dependencies,omitempty"`
+	Transports            map[string]ModuleInfo `json:"transports,omitempty"`
+	Events                map[string]ModuleInfo `json:"events,omitempty"`
+	Plugins               map[string]ModuleInfo `json:"plugins,omitempty"`
+}
+
+// HasPlugin 服务器是否加载了插件
+func (si *ServerInfo) HasPlugin(name string) bool {
+	_, ok := si.Plugins[name]
+	return ok
+}
+
+// PluginNames 已加载的插件名
+func (si *ServerInfo) PluginNames() (names []string) {
+	for name := range si.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Info 连接服务器查询服务器信息，查询后断开连接
+func (cli *Client) Info() (info *ServerInfo, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return cli.InfoContext(ctx)
+}
+
+// InfoContext 连接服务器查询服务器信息，ctx 控制连接和等待时间
+func (cli *Client) InfoContext(ctx context.Context) (info *ServerInfo, err error) {
+	var tr Transport
+	if tr, err = cli.dialTransport(ctx); err != nil {
+		return
+	}
+	// 临时连接，不做断线重连
+	var icli = *cli
+	icli.reconnect = ReconnectPolicy{}
+	js := newJanus(&icli, tr)
+	js.startReader(tr)
+	defer js.Destroy()
+	return js.requestInfo(ctx)
+}
+
+// Info 查询服务器信息，结果会缓存用于 Attach 时检查插件
+func (js *Janus) Info(ctx context.Context) (info *ServerInfo, err error) {
+	if info, err = js.requestInfo(ctx); err == nil {
+		js.infoLock.Lock()
+		js.info = info
+		js.infoLock.Unlock()
+	}
+	return
+}
+
+// cachedInfo 返回缓存的服务器信息，没有时查询
+func (js *Janus) cachedInfo(ctx context.Context) (info *ServerInfo, err error) {
+	js.infoLock.Lock()
+	info = js.info
+	js.infoLock.Unlock()
+	if info == nil {
+		info, err = js.Info(ctx)
+	}
+	return
+}
+
+func (js *Janus) requestInfo(ctx context.Context) (info *ServerInfo, err error) {
+	var req janusRequest
+	var resp *JanusResponse
+	req.Janus = "info"
+	if resp, err = js.requestContext(ctx, &req); err != nil {
+		err = fmt.Errorf("janus info fail:%w", err)
+		return
+	}
+	if err = resp.HasError("info"); err != nil {
+		return
+	}
+	info = new(ServerInfo)
+	if err = json.Unmarshal(resp.oriMsg, info); err != nil {
+		info = nil
+		err = fmt.Errorf("janus info decode fail:%w", err)
+	}
+	return
+}
+
+// checkPlugin 开启插件检查时，attach 前确认服务器加载了插件
+func (js *Janus) checkPlugin(ctx context.Context, pluginName string) (err error) {
+	var info *ServerInfo
+	if !js.cli.checkPlugins {
+		return
+	}
+	if info, err = js.cachedInfo(ctx); err != nil {
+		return
+	}
+	if !info.HasPlugin(pluginName) {
+		err = fmt.Errorf("%w: %s not loaded, available %v", ErrPluginNotFound, pluginName, info.PluginNames())
+	}
+	return
+}
+
+// SetPluginCheck 开启后 Attach 前先用 info 检查插件是否加载
+func (cli *Client) SetPluginCheck(check bool) *Client {
+	cli.checkPlugins = check
+	return cli
+}
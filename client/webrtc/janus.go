@@ -8,12 +8,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/finove/golibused/pkg/logger"
 	"github.com/gorilla/websocket"
 )
 
@@ -37,72 +38,137 @@ const (
 
 // Client janus client
 type Client struct {
-	Server string
-	Secret string
-}
-
-// NewClient 新的janus客户端，配置服务器和密码
-func NewClient(server, secret string) (cli *Client) {
+	Server           string
+	Secret           string
+	dialer           TransportDialer
+	auth             AuthProvider
+	reconnect        ReconnectPolicy
+	tlsConfig        *tls.Config
+	proxy            func(*http.Request) (*url.URL, error)
+	header           http.Header
+	handshakeTimeout time.Duration
+	readLimit        int64
+	wsDialer         *websocket.Dialer
+	httpClient       *http.Client
+	checkPlugins     bool // Attach 前用 info 检查插件
+	logger           Logger
+	metrics          Metrics
+	// keepalive 配置
+	keepaliveInterval time.Duration
+	keepaliveMisses   int
+	clock             Clock
+	recorder          *Recorder
+}
+
+// NewClient 新的janus客户端，配置服务器和密码，TLS、代理等通过 opts 配置
+func NewClient(server, secret string, opts ...ClientOption) (cli *Client) {
 	cli = new(Client)
 	cli.Server = server
 	cli.Secret = secret
-	websocket.DefaultDialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	cli.reconnect = DefaultReconnectPolicy
+	cli.proxy = http.ProxyFromEnvironment
+	cli.header = http.Header{}
+	cli.handshakeTimeout = 45 * time.Second
+	cli.logger = DefaultLogger(LevelInfo)
+	cli.metrics = nopMetrics{}
+	cli.keepaliveInterval = DefaultKeepaliveInterval
+	cli.keepaliveMisses = DefaultKeepaliveMisses
+	cli.clock = realClock{}
+	for _, opt := range opts {
+		opt(cli)
+	}
+	cli.setupDialers()
 	return
 }
 
+// SetTransport 设置自定义传输，不设置时按服务器地址 scheme 选择 WebSocket 或 HTTP
+func (cli *Client) SetTransport(f TransportDialer) *Client {
+	if f != nil {
+		cli.dialer = f
+	}
+	return cli
+}
+
 // NewJanus 创建新的janus会话
 func (cli *Client) NewJanus() (js *Janus, err error) {
-	var h = http.Header{}
-	js = &Janus{
-		cli: cli,
-	}
-	h.Add("Sec-WebSocket-Protocol", "janus-protocol") // janus-admin-protocol
-	js.conn, _, err = websocket.DefaultDialer.Dial(js.GetServer(), h)
-	if err != nil {
-		js = nil
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return cli.NewJanusContext(ctx)
+}
+
+// NewJanusContext 创建新的janus会话，ctx 控制连接和创建会话的等待时间
+func (cli *Client) NewJanusContext(ctx context.Context) (js *Janus, err error) {
+	return cli.newJanusContext(ctx, nil)
+}
+
+// newJanusContext 创建会话，setup 在内部协程启动之前调用，用于设置协程会读取的字段
+func (cli *Client) newJanusContext(ctx context.Context, setup func(js *Janus)) (js *Janus, err error) {
+	var tr Transport
+	if tr, err = cli.dialTransport(ctx); err != nil {
 		return
 	}
-	logger.Info("new session connect to janus webrtc server %s ok", js.GetServer())
-	js.disconn = make(chan bool)
-	js.idEncode = base32.NewEncoding("ABCDEFGHJKLabcdefghjkmnopqMNWYZz")
-	go js.consumeEvent()
-	if err = js.newSession(); err != nil {
+	js = newJanus(cli, tr)
+	if setup != nil {
+		setup(js)
+	}
+	js.log(LevelInfo, "janus connected", F("server", js.GetServer()))
+	js.startReader(tr)
+	if err = js.newSession(ctx); err != nil {
 		js.Destroy()
 		js = nil
 		return
 	}
-	go func() {
-	OUTLOOP:
-		for {
-			select {
-			case <-js.disconn:
-				js.Destroy()
-				break OUTLOOP
-			case <-time.After(30 * time.Second):
-				if js.GetSessionID() > 0 {
-					js.keepAlive()
-				}
-			}
-		}
-		logger.Info("session %d keep alive finish", js.GetSessionID())
-		if js.callback != nil {
-			go js.callback(js, "session finish")
-		}
-	}()
+	js.wg.Add(1)
+	go js.keepaliveLoop()
 	return
 }
 
+func newJanus(cli *Client, tr Transport) *Janus {
+	return &Janus{
+		cli:      cli,
+		tr:       tr,
+		disconn:  make(chan bool, 1),
+		quit:     make(chan struct{}),
+		idEncode: base32.NewEncoding("ABCDEFGHJKLabcdefghjkmnopqMNWYZz"),
+		state:    newStateWatch(string(SessionStateConnecting), string(SessionStateClosed), ErrSessionNotFound),
+	}
+}
+
 // Janus session
 type Janus struct {
 	cli      *Client
-	id       int64 // session id
-	wlock    sync.Mutex
+	id       int64 // session id，原子读写
+	state    *stateWatch
 	disconn  chan bool
-	callback func(*Janus, string)
-	conn     *websocket.Conn
+	callback func(*Janus, SessionEvent)
+	tr       Transport
+	trLock   sync.RWMutex
+	closed   bool          // 主动释放，不再重连
+	quit     chan struct{} // 主动释放时关闭，用于中断重连等待
 	idEncode *base32.Encoding
 	handles  sync.Map
-	waitEv   sync.Map
+	txns     sync.Map // 等待响应的请求，transaction -> *Transaction
+	// 等待交给 callback 的会话事件，按顺序投递
+	emitLock  sync.Mutex
+	emitQueue []SessionEvent
+	emitting  bool
+	// 新 handle 的事件通道配置
+	eventBuffer int
+	eventPolicy OverflowPolicy
+	// 服务器信息缓存
+	infoLock sync.Mutex
+	info     *ServerInfo
+	// 连接池创建的会话
+	pool     *Pool
+	movedTo  *Janus
+	failover bool // 已开始故障转移，只转移一次
+	// 内部协程，Close 时等待结束
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+	// keepalive 连续没有响应的次数，原子读写
+	missed   int32
+	lostOnce sync.Once
 }
 
 // GetServer 返回服务器地址
@@ -112,23 +178,32 @@ func (js *Janus) GetServer() string {
 
 // IsConnected 是否连接到服务器
 func (js *Janus) IsConnected() bool {
-	return js.conn != nil
+	return js.transport() != nil
 }
 
 // GetSessionID 获取会话ID
 func (js *Janus) GetSessionID() int64 {
-	return js.id
+	return atomic.LoadInt64(&js.id)
 }
 
 // Attach 绑定插件，创建handle
 func (js *Janus) Attach(pluginName, tag string) (h *Handle, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return js.AttachContext(ctx, pluginName, tag)
+}
+
+// AttachContext 绑定插件，创建handle，ctx 控制等待时间
+func (js *Janus) AttachContext(ctx context.Context, pluginName, tag string) (h *Handle, err error) {
 	var req janusRequest
 	var resp *JanusResponse
+	if err = js.checkPlugin(ctx, pluginName); err != nil {
+		return
+	}
 	req.Janus = "attach"
-	req.APISecret = js.cli.Secret
-	req.SessionID = js.id
+	req.SessionID = js.GetSessionID()
 	req.Plugin = pluginName
-	resp, err = js.requestWait(&req)
+	resp, err = js.requestContext(ctx, &req)
 	if err != nil {
 		err = fmt.Errorf("janus plugin attach %s fail:%w", pluginName, err)
 		return
@@ -141,33 +216,58 @@ func (js *Janus) Attach(pluginName, tag string) (h *Handle, err error) {
 		plugin: pluginName,
 		tag:    tag,
 		Status: "init",
+		state:  newStateWatch(string(HandleStateAttached), string(HandleStateDetached), ErrHandleNotFound),
 	}
 	h.ID = resp.dataID()
 	h.Ctx = context.Background()
+	h.stream = newEventStream(js.eventConfig())
+	js.wg.Add(1)
+	go func() {
+		defer js.wg.Done()
+		h.eventLoop()
+	}()
 	js.handles.Store(h.ID, h)
-	logger.Info("got %s %s handle ID %d", tag, pluginName, h.ID)
+	js.cli.metrics.HandleAttached(h.ID, pluginName)
+	h.log(LevelInfo, "handle attached")
 	return
 }
 
-// Destroy 释放会话，断开连接
+// Destroy 断开连接，不通知服务器，服务器上的会话和 handle 等超时释放，需要正常释放时使用 Close
 func (js *Janus) Destroy() (err error) {
-	if js.conn == nil {
+	js.trLock.Lock()
+	tr := js.tr
+	wasClosed := js.closed
+	js.tr = nil
+	js.closed = true
+	if !wasClosed {
+		close(js.quit)
+	}
+	js.trLock.Unlock()
+	js.setState(SessionStateClosed)
+	if !wasClosed && js.GetSessionID() > 0 {
+		js.handles.Range(func(key interface{}, value interface{}) bool {
+			if h, ok := value.(*Handle); ok {
+				js.removeHandle(h)
+				h.setState(HandleStateDetached)
+				h.closeEvents()
+			}
+			return true
+		})
+		js.cli.metrics.SessionClosed()
+	}
+	if tr == nil {
 		return
 	}
-	defer func() {
-		js.conn.Close()
-		js.conn = nil
-	}()
-	js.wlock.Lock()
-	err = js.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-	js.wlock.Unlock()
+	err = tr.Close()
 	return
 }
 
-// SetEventCallBack 设置事件处理回调
-func (js *Janus) SetEventCallBack(f func(*Janus, string)) *Janus {
+// SetEventCallBack 设置会话事件回调，如断线重连、会话丢失和会话结束，事件按发生顺序在单独的协程中依次回调
+func (js *Janus) SetEventCallBack(f func(*Janus, SessionEvent)) *Janus {
 	if f != nil {
+		js.trLock.Lock()
 		js.callback = f
+		js.trLock.Unlock()
 	}
 	return js
 }
@@ -176,69 +276,70 @@ func (js *Janus) SetEventCallBack(f func(*Janus, string)) *Janus {
 func (js *Janus) ShowHandles() {
 	js.handles.Range(func(key interface{}, value interface{}) bool {
 		if h, ok := value.(*Handle); ok {
-			logger.Info("%d handle %s", js.id, h.Summary())
+			h.log(LevelInfo, "handle "+h.Summary())
 		}
 		return true
 	})
 }
 
-func (js *Janus) keepAlive() {
-	var req janusRequest
-	if js.conn == nil {
-		return
-	}
-	req.Janus = "keepalive"
-	req.APISecret = js.cli.Secret
-	req.SessionID = js.id
-	js.requestWait(&req)
-}
-
-func (js *Janus) newSession() (err error) {
+func (js *Janus) newSession(ctx context.Context) (err error) {
 	var req janusRequest
 	var resp *JanusResponse
+	var start = time.Now()
 	req.Janus = "create"
-	req.APISecret = js.cli.Secret
-	resp, err = js.requestWait(&req)
+	resp, err = js.requestContext(ctx, &req)
 	if err != nil {
 		err = fmt.Errorf("newSession create fail:%w", err)
 		return
 	}
-	js.id = resp.dataID()
-	logger.Info("new janus session ID %d", js.id)
+	if err = resp.HasError("create"); err != nil {
+		return
+	}
+	atomic.StoreInt64(&js.id, resp.dataID())
+	js.setState(SessionStateActive)
+	js.cli.metrics.SessionOpened()
+	js.log(LevelInfo, "session created", latency(start))
 	return
 }
 
 func (js *Janus) requestWait(req *janusRequest, timeOuts ...time.Duration) (resp *JanusResponse, err error) {
-	var timeOut time.Duration = 5 * time.Second
-	var ev = newEventAck(req.Transaction)
-	if js.conn == nil {
-		err = fmt.Errorf("no connection to the server")
-		return
-	}
+	var timeOut = DefaultRequestTimeout
 	if len(timeOuts) > 0 {
 		timeOut = timeOuts[0]
 	}
-	if req.Transaction == "" {
-		req.Transaction = js.newTransactionID()
+	ctx, cancel := context.WithTimeout(context.Background(), timeOut)
+	defer cancel()
+	return js.requestContext(ctx, req)
+}
+
+// requestContext 发送请求并等待同一 transaction 的 ack 或同步响应，ctx 结束时放弃等待
+func (js *Janus) requestContext(ctx context.Context, req *janusRequest) (resp *JanusResponse, err error) {
+	var t *Transaction
+	if t, err = js.begin(ctx, req); err != nil {
+		return
+	}
+	defer t.Close()
+	return js.awaitReply(ctx, t, req)
+}
+
+// awaitReply 等待 ack 或同步响应，记录延迟和结果
+func (js *Janus) awaitReply(ctx context.Context, t *Transaction, req *janusRequest) (resp *JanusResponse, err error) {
+	if resp, err = t.Reply(ctx); err == nil {
+		js.log(LevelDebug, "janus response", F(FieldTransaction, req.Transaction), F(FieldEvent, resp.Janus), latency(t.start))
+		js.cli.metrics.Latency(req.Janus, PhaseAck, time.Since(t.start))
+	} else {
+		js.log(LevelWarn, "janus request fail", F(FieldTransaction, req.Transaction), F(FieldEvent, req.Janus), latency(t.start), F(FieldError, err))
 	}
-	js.waitEv.Store(req.Transaction, ev)
-	defer js.waitEv.Delete(req.Transaction)
-	js.wlock.Lock()
-	err = js.conn.WriteJSON(req)
-	js.wlock.Unlock()
-	if req.Janus != "keepalive" {
-		logger.Info("wait request %s", req)
+	if resp == nil || resp.Janus != "ack" {
+		js.observeResult(req.Janus, resp, err)
 	}
-	select {
-	case <-ev.done:
-		resp = ev.value
-	case <-time.After(timeOut):
-		err = fmt.Errorf("request timeout with transaction %s", req.Transaction)
+	if err == nil && req.Janus != "destroy" && isSessionGone(resp.HasError()) {
+		js.sessionGone("no such session")
 	}
 	return
 }
 
-func (js *Janus) consumeEvent() {
+func (js *Janus) consumeEvent(tr Transport) {
 	var err error
 	var evJanus = map[string]bool{
 		"event":     true,
@@ -248,17 +349,19 @@ func (js *Janus) consumeEvent() {
 		"hangup":    true,
 		"detached":  true,
 		"slowlink":  true,
+		"trickle":   true,
+		"timeout":   true,
 	}
-	if js.conn == nil {
-		logger.Error("consumeEvent no connection to the server")
+	if tr == nil {
+		js.log(LevelError, "consumeEvent no connection to the server")
 		return
 	}
 	for {
 		var message []byte
 		var notify JanusResponse
-		_, message, err = js.conn.ReadMessage()
+		message, err = tr.Read()
 		if err != nil {
-			logger.Warning("read websocket message fail:%v", err)
+			js.log(LevelWarn, "read transport message fail", F(FieldError, err))
 			break
 		}
 		err = json.Unmarshal(message, &notify)
@@ -266,26 +369,24 @@ func (js *Janus) consumeEvent() {
 			continue
 		}
 		notify.oriMsg = message
-		if v, ok := evJanus[notify.Janus]; ok && v {
-			go js.processEvent(&notify)
-		} else if notify.Transaction != "" {
-			// 响应消息按序处理，不在 go 线程中处理
-			if value, ok := js.waitEv.Load(notify.Transaction); ok {
-				if ea, ok := value.(*eventAck); ok {
-					// 异步接口会先发一个 ack 确认消息，然后再发一个 event 响应处理结果
-					// 也有可能先发一个 event 响应处理结果，然后再发一个 ack 确认消息，异步关系导致次序不一定
-					// logger.Info("janus request %s %s", notify.Transaction, notify.Janus)
-					ea.value = &notify
-					close(ea.done)
-				}
-				continue
-			}
-		} else {
-			logger.Error("should not happen, got message from session:%s", string(message))
+		if notify.Transaction != "" {
+			// 异步请求的 ack 和 event 到达次序不一定，都按 transaction 交给等待的请求
+			js.correlate(&notify, evJanus[notify.Janus])
 		}
+		if evJanus[notify.Janus] {
+			// 在读协程中分发，保证同一 handle 的事件按收到的顺序处理
+			js.processEvent(&notify)
+		} else if notify.Transaction == "" {
+			js.log(LevelError, "unexpected message without transaction", F("message", js.payload(message)))
+		}
+	}
+	js.log(LevelWarn, "consumeEvent finish", F(FieldError, err))
+	js.trLock.RLock()
+	current, closed := js.tr == tr, js.closed
+	js.trLock.RUnlock()
+	if closed || (current && !js.reconnectSession(tr)) {
+		js.disconn <- true
 	}
-	js.disconn <- true
-	logger.Warning("session %d consumeEvent finish %v", js.GetSessionID(), err)
 }
 
 func (js *Janus) processEvent(event *JanusResponse) {
@@ -296,18 +397,22 @@ func (js *Janus) processEvent(event *JanusResponse) {
 	if event.Sender != 0 {
 		if value, ok = js.handles.Load(event.Sender); ok {
 			if h, ok = value.(*Handle); ok {
-				h.processEvent(event)
+				h.dispatch(event)
 			}
 		}
 		return
 	}
-	logger.Info("session %d get event from handle %d, event %s", js.id, event.Sender, string(event.oriMsg))
+	js.log(LevelInfo, "session event", F(FieldEvent, event.Janus), F("message", js.payload(event.oriMsg)))
+	if event.Janus == "timeout" {
+		// 服务器上的会话已超时释放
+		js.sessionGone("timeout")
+	}
 }
 
 func (js *Janus) newTransactionID() (key string) {
 	var tmpBuff = make([]byte, 10)
 	if n, err := rand.Read(tmpBuff); err != nil || n != 10 {
-		logger.Warning("generator transaction id %d, %v", n, err)
+		js.log(LevelWarn, "generate transaction id fail", F("read", n), F(FieldError, err))
 	}
 	key = js.idEncode.EncodeToString(tmpBuff[:])
 	return
@@ -315,16 +420,29 @@ func (js *Janus) newTransactionID() (key string) {
 
 // Handle janus handle
 type Handle struct {
-	js         *Janus
-	plugin     string
-	tag        string
-	ID         int64
-	Status     string
-	Ctx        context.Context `json:"-"`
-	callBack   func(*Handle, string, interface{})
-	asyncQueue sync.Map // 异步响应队列
-	webrtcUp   bool
-	dataReady  bool
+	js          *Janus
+	plugin      string
+	tag         string
+	ID          int64
+	Status      string
+	Ctx         context.Context `json:"-"`
+	callBack    func(*Handle, string, interface{})
+	stream      *eventStream
+	onCandidate func(*ICECandidate)
+	waitLock    sync.Mutex
+	waiters     []*eventWaiter
+	// 等待在回调协程中执行的用户回调，按事件顺序执行
+	callLock  sync.Mutex
+	callQueue []func()
+	calling   bool
+	// 非 SIP handle 发送 DTMF 的音轨
+	dtmfTrack DtmfWriter
+	// 生命周期状态，stateLock 保护 tag,callBack,onCandidate,webrtcUp,dataReady,roster,Status,Ctx
+	state     *stateWatch
+	stateLock sync.Mutex
+	webrtcUp  bool
+	dataReady bool
+	roster    *Roster
 	// iceState   bool
 	// mediaState bool
 	// slowLink   bool
@@ -337,6 +455,8 @@ func (h *Handle) GetID() int64 {
 
 // GetTag 获取handle tag
 func (h *Handle) GetTag() string {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
 	return h.tag
 }
 
@@ -345,14 +465,24 @@ func (h *Handle) GetPlugin() string {
 	return h.plugin
 }
 
+// DataReady 数据通道是否可用
 func (h *Handle) DataReady() (yes bool) {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
 	return h.dataReady
 }
 
+// context 获取当前上下文
+func (h *Handle) context() context.Context {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	return h.Ctx
+}
+
 // ContextString 获取上下文字段值
 func (h *Handle) ContextString(key interface{}) (value string) {
 	var ok bool
-	if v := h.Ctx.Value(key); v != nil {
+	if v := h.context().Value(key); v != nil {
 		if value, ok = v.(string); !ok {
 			value = fmt.Sprintf("%v", v)
 		}
@@ -363,7 +493,7 @@ func (h *Handle) ContextString(key interface{}) (value string) {
 // ContextInt64 获取上下文字段值
 func (h *Handle) ContextInt64(key interface{}) (value int64) {
 	var ok bool
-	if v := h.Ctx.Value(key); v != nil {
+	if v := h.context().Value(key); v != nil {
 		if value, ok = v.(int64); !ok {
 			value1 := fmt.Sprintf("%v", v)
 			value, _ = strconv.ParseInt(value1, 0, 64)
@@ -374,31 +504,24 @@ func (h *Handle) ContextInt64(key interface{}) (value int64) {
 
 // Send 发送消息给插件
 func (h *Handle) Send(reqBody interface{}, jsep *Jsep, pluginResp ...interface{}) (resp *JanusResponse, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout+DefaultEventTimeout)
+	defer cancel()
+	return h.SendContext(ctx, reqBody, jsep, pluginResp...)
+}
+
+// SendContext 发送消息给插件，ctx 控制等待 ack 和异步事件的时间
+func (h *Handle) SendContext(ctx context.Context, reqBody interface{}, jsep *Jsep, pluginResp ...interface{}) (resp *JanusResponse, err error) {
 	var req janusRequest
 	req.Janus = "message"
 	req.SessionID = h.js.GetSessionID()
 	req.HandleID = h.GetID()
-	req.APISecret = h.js.cli.Secret
 	req.Body = reqBody
 	req.Jsep = jsep
-	req.Transaction = h.js.newTransactionID()
-	ea := newEventAck(req.Transaction)
-	h.asyncQueue.Store(req.Transaction, ea)
-	resp, err = h.js.requestWait(&req)
-	if err != nil {
-		err = fmt.Errorf("handle %s send message fail:%w", h.tag, err)
-		return
-	}
-	if resp.Janus == "ack" {
-		// wait event
-		select {
-		case <-ea.done:
-			resp = ea.value
-		case <-time.After(15 * time.Second):
-			err = fmt.Errorf("send wait response timeout with transaction %s", req.Transaction)
-		}
+	if jsep != nil {
+		h.setState(HandleStateNegotiating, HandleStateAttached, HandleStateHungup)
 	}
-	if err != nil {
+	if resp, err = h.requestAsync(ctx, &req); err != nil {
+		err = fmt.Errorf("handle %s send message fail:%w", h.GetTag(), err)
 		return
 	}
 	if err = resp.HasError(); err != nil {
@@ -410,9 +533,25 @@ func (h *Handle) Send(reqBody interface{}, jsep *Jsep, pluginResp ...interface{}
 	return
 }
 
-// Dtmf 发送DTMF tone
-func (h *Handle) Dtmf() (err error) {
-	err = fmt.Errorf("not implemented")
+// Dtmf 发送DTMF tone，SIP handle 使用 dtmf_info，其他插件通过 SetDtmfTrack 设置的音轨发送 RFC 4733 telephone-event
+func (h *Handle) Dtmf(digits string, duration ...time.Duration) (err error) {
+	return h.DtmfContext(context.Background(), digits, duration...)
+}
+
+// DtmfContext 发送DTMF tone，ctx 结束时停止发送
+func (h *Handle) DtmfContext(ctx context.Context, digits string, duration ...time.Duration) (err error) {
+	var d = DefaultDtmfDuration
+	if len(duration) > 0 && duration[0] > 0 {
+		d = duration[0]
+	}
+	if err = validDtmf(digits); err != nil {
+		return
+	}
+	if h.plugin == PluginSIP {
+		err = h.sipDtmf(ctx, digits, d)
+	} else {
+		err = h.rtpDtmf(ctx, digits, d)
+	}
 	return
 }
 
@@ -430,66 +569,129 @@ func (h *Handle) Data(data interface{}) (err error) {
 
 // Hangup 挂断连接
 func (h *Handle) Hangup() (err error) {
-	err = fmt.Errorf("not implemented")
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout+DefaultEventTimeout)
+	defer cancel()
+	return h.HangupContext(ctx)
+}
+
+// HangupContext 发送 hangup 请求关闭 PeerConnection，连接已建立时等待 hangup 事件
+func (h *Handle) HangupContext(ctx context.Context) (err error) {
+	var req janusRequest
+	var resp *JanusResponse
+	h.stateLock.Lock()
+	var connected = h.webrtcUp || h.dataReady
+	h.stateLock.Unlock()
+	req.Janus = "hangup"
+	req.SessionID = h.js.GetSessionID()
+	req.HandleID = h.GetID()
+	hungup, cancel := h.expectEvent("hangup")
+	defer cancel()
+	if resp, err = h.js.requestContext(ctx, &req); err != nil {
+		err = fmt.Errorf("handle %s hangup fail:%w", h.GetTag(), err)
+		return
+	}
+	if err = resp.HasError("hangup"); err != nil {
+		return
+	}
+	if connected {
+		select {
+		case <-hungup:
+		case <-ctx.Done():
+			err = contextError(ctx, "hangup event", req.Transaction)
+			return
+		}
+	}
+	h.stateLock.Lock()
+	h.webrtcUp = false
+	h.dataReady = false
+	h.stateLock.Unlock()
+	h.setState(HandleStateHungup)
 	return
 }
 
 // Detach 解绑handle，释放
 func (h *Handle) Detach() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout+DefaultEventTimeout)
+	defer cancel()
+	return h.DetachContext(ctx)
+}
+
+// DetachContext 解绑handle，释放，ctx 控制等待时间
+func (h *Handle) DetachContext(ctx context.Context) (err error) {
 	var req janusRequest
 	var resp *JanusResponse
 	req.Janus = "detach"
 	req.SessionID = h.js.GetSessionID()
 	req.HandleID = h.GetID()
-	req.APISecret = h.js.cli.Secret
-	req.Transaction = h.js.newTransactionID()
-	ea := newEventAck(req.Transaction)
-	h.asyncQueue.Store(req.Transaction, ea)
-	resp, err = h.js.requestWait(&req)
-	if err != nil {
+	if resp, err = h.requestAsync(ctx, &req); err != nil {
 		err = fmt.Errorf("janus plugin detach %s fail:%w", h.plugin, err)
 		return
 	}
-	if resp.Janus == "ack" {
-		// wait event
-		select {
-		case <-ea.done:
-			resp = ea.value
-		case <-time.After(15 * time.Second):
-			err = fmt.Errorf("send wait response timeout with transaction %s", req.Transaction)
-		}
+	if err = resp.HasError("detach"); err == nil {
+		h.js.removeHandle(h)
+		h.setState(HandleStateDetached)
+		h.detached(ctx)
 	}
-	if err != nil {
+	return
+}
+
+// requestAsync 发送请求，收到 ack 时继续等待同一 transaction 的第一个事件，事件可能先于 ack 到达
+func (h *Handle) requestAsync(ctx context.Context, req *janusRequest) (resp *JanusResponse, err error) {
+	var t *Transaction
+	if t, err = h.js.begin(ctx, req); err != nil {
 		return
 	}
-	if err = resp.HasError("detach"); err == nil {
-		h.js.handles.Delete(h.GetID())
+	defer t.Close()
+	if resp, err = h.js.awaitReply(ctx, t, req); err != nil {
+		return
+	}
+	if resp.Janus == "ack" {
+		if resp, err = t.Next(ctx); err == nil {
+			h.log(LevelDebug, "janus async event", F(FieldTransaction, req.Transaction), F(FieldEvent, resp.Janus), latency(t.start))
+			h.js.cli.metrics.Latency(req.Janus, PhaseEvent, time.Since(t.start))
+		}
+		h.js.observeResult(req.Janus, resp, err)
 	}
 	return
 }
 
 // SetTag 设置标签
 func (h *Handle) SetTag(tag string) *Handle {
+	h.stateLock.Lock()
 	h.tag = tag
+	h.stateLock.Unlock()
 	return h
 }
 
 // SetStatus 设置状态
 func (h *Handle) SetStatus(st string) *Handle {
+	h.stateLock.Lock()
 	h.Status = st
+	h.stateLock.Unlock()
 	return h
 }
 
+// GetStatus 获取状态
+func (h *Handle) GetStatus() string {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	return h.Status
+}
+
 // SetContext 设置状态
 func (h *Handle) SetContext(key, val interface{}) *Handle {
+	h.stateLock.Lock()
 	h.Ctx = context.WithValue(h.Ctx, key, val)
+	h.stateLock.Unlock()
 	return h
 }
 
-// SetEventCallBack 设置事件处理回调
+// SetEventCallBack 设置事件处理回调，回调在单独的协程中按事件顺序调用，可以在回调中调用 Hangup、WaitState 等接口
 func (h *Handle) SetEventCallBack(f func(*Handle, string, interface{})) *Handle {
 	if f != nil {
+		h.stateLock.Lock()
 		h.callBack = f
+		h.stateLock.Unlock()
 	}
 	return h
 }
@@ -497,10 +699,11 @@ func (h *Handle) SetEventCallBack(f func(*Handle, string, interface{})) *Handle
 // Summary handle summary
 func (h *Handle) Summary() string {
 	var out strings.Builder
-	out.WriteString(fmt.Sprintf("tag %s", h.tag))
+	out.WriteString(fmt.Sprintf("tag %s", h.GetTag()))
 	out.WriteString(fmt.Sprintf(" plugin %s", h.plugin))
 	out.WriteString(fmt.Sprintf(" ID %d", h.ID))
-	out.WriteString(fmt.Sprintf(" status %s", h.Status))
+	out.WriteString(fmt.Sprintf(" status %s", h.GetStatus()))
+	out.WriteString(fmt.Sprintf(" state %s", h.State()))
 	return out.String()
 }
 
@@ -509,7 +712,7 @@ func (h *Handle) ShowSiblings() {
 	h.js.ShowHandles()
 }
 
-func (h *Handle) onMessage(data json.RawMessage, jsep *Jsep) {
+func (h *Handle) onMessage(data json.RawMessage, jsep *Jsep) (decoded interface{}) {
 	if h.plugin == PluginVideoRoom {
 		var roomEvent VideoRoomResponse
 		json.Unmarshal(data, &roomEvent)
@@ -517,22 +720,29 @@ func (h *Handle) onMessage(data json.RawMessage, jsep *Jsep) {
 		case "incoming-data":
 			h.onData(roomEvent.Data)
 		case "talking", "stopped-talking", "active-speaker":
-			logger.Info("handle %s(%d) get event %s room %d, id %d, level %v", h.tag, h.GetID(), roomEvent.VideoRoom, roomEvent.Room, roomEvent.ID, roomEvent.AudioLevelAvg)
+			h.log(LevelInfo, "videoroom event", F(FieldEvent, roomEvent.VideoRoom), F("room", roomEvent.Room), F("id", roomEvent.ID), F("level", roomEvent.AudioLevelAvg))
 		case "joined":
-			logger.Info("handle %s(%d) %s room %d(%s) with participant id %d", h.tag, h.GetID(), roomEvent.VideoRoom, roomEvent.Room, roomEvent.Description, roomEvent.ID)
-			h.Ctx = context.WithValue(h.Ctx, CtxParticipantID, roomEvent.ID)
+			h.log(LevelInfo, "videoroom event", F(FieldEvent, roomEvent.VideoRoom), F("room", roomEvent.Room), F("description", roomEvent.Description), F("id", roomEvent.ID))
+			h.SetContext(CtxParticipantID, roomEvent.ID)
+			if h.js.pool != nil {
+				h.js.pool.noteRoom(h.js, roomEvent.Room)
+			}
 		case "slow_link":
-			logger.Info("handle %s(%d) get event %s current-bitrate %d", h.tag, h.GetID(), roomEvent.VideoRoom, roomEvent.CurrentBitrate)
+			h.log(LevelInfo, "videoroom event", F(FieldEvent, roomEvent.VideoRoom), F("current-bitrate", roomEvent.CurrentBitrate))
 		case "event":
-			logger.Info("handle %s(%d) get event %s=%s with jsep %s", h.tag, h.GetID(), roomEvent.VideoRoom, string(data), jsep.Type)
+			h.log(LevelInfo, "videoroom event", F(FieldEvent, roomEvent.VideoRoom), F("data", h.js.payload(data)), F("jsep", jsep.Type))
 		case "dataready":
+			h.stateLock.Lock()
 			h.dataReady = true
+			h.stateLock.Unlock()
 		default:
-			logger.Info("handle %s(%d) get event %s=%s", h.tag, h.GetID(), roomEvent.VideoRoom, string(data))
+			h.log(LevelInfo, "videoroom event", F(FieldEvent, roomEvent.VideoRoom), F("data", h.js.payload(data)))
 		}
-		if h.callBack != nil {
-			h.callBack(h, roomEvent.VideoRoom, &roomEvent)
+		if r := h.Roster(); r != nil {
+			r.apply(data)
 		}
+		h.callback(roomEvent.VideoRoom, &roomEvent)
+		decoded = &roomEvent
 	} else if h.plugin == PluginSIP {
 		var sipEvent SipEvent
 		json.Unmarshal(data, &sipEvent)
@@ -542,59 +752,127 @@ func (h *Handle) onMessage(data json.RawMessage, jsep *Jsep) {
 		switch sipEvent.Sip {
 		case "event":
 		default:
-			logger.Info("handle %s(%d) get sip event %s=%s", h.tag, h.GetID(), sipEvent.Sip, string(data))
-		}
-		if h.callBack != nil {
-			h.callBack(h, sipEvent.Sip, &sipEvent)
+			h.log(LevelInfo, "sip event", F(FieldEvent, sipEvent.Sip), F("data", h.js.payload(data)))
 		}
+		h.callback(sipEvent.Sip, &sipEvent)
+		decoded = &sipEvent
 	}
+	return
 }
 
 func (h *Handle) onData(data json.RawMessage) {
-	logger.Info("handle %s(%d) onData %s", h.tag, h.GetID(), string(data))
+	h.log(LevelInfo, "incoming data", F("data", string(data)))
+}
+
+// expectEvent 等待下一个指定名称的事件，需在发送请求前调用，避免错过事件
+func (h *Handle) expectEvent(name string) (ch <-chan *JanusResponse, cancel func()) {
+	var w = &eventWaiter{name: name, ch: make(chan *JanusResponse, 1)}
+	h.waitLock.Lock()
+	h.waiters = append(h.waiters, w)
+	h.waitLock.Unlock()
+	cancel = func() {
+		h.waitLock.Lock()
+		for i, item := range h.waiters {
+			if item == w {
+				h.waiters = append(h.waiters[:i], h.waiters[i+1:]...)
+				break
+			}
+		}
+		h.waitLock.Unlock()
+	}
+	return w.ch, cancel
 }
 
-func (h *Handle) processEvent(event *JanusResponse) {
-	if event.Transaction != "" {
-		if value, ok := h.asyncQueue.Load(event.Transaction); ok {
-			if ea, ok := value.(*eventAck); ok {
-				ea.value = event
-				close(ea.done)
-			}
-			h.asyncQueue.Delete(event.Transaction)
-			// return
+// notifyWaiters 唤醒等待该事件的调用
+func (h *Handle) notifyWaiters(event *JanusResponse) {
+	h.waitLock.Lock()
+	var kept = h.waiters[:0]
+	for _, w := range h.waiters {
+		if w.name == event.Janus {
+			w.ch <- event
+		} else {
+			kept = append(kept, w)
 		}
 	}
+	h.waiters = kept
+	h.waitLock.Unlock()
+}
+
+func (h *Handle) processEvent(event *JanusResponse) {
+	var header = newEventHeader(event)
+	defer h.notifyWaiters(event)
 	switch event.Janus {
 	case "event":
 		if event.PluginData != nil && event.PluginData.Plugin == h.plugin && event.PluginData.Data != nil {
-			h.onMessage(event.PluginData.Data, &event.Jsep)
+			msg := &PluginMessage{
+				eventHeader: header,
+				Plugin:      event.PluginData.Plugin,
+				Transaction: event.Transaction,
+				Data:        event.PluginData.Data,
+			}
+			if event.Jsep.Type != "" || event.Jsep.SDP != "" {
+				jsep := event.Jsep
+				msg.Jsep = &jsep
+				h.setState(HandleStateNegotiating, HandleStateAttached, HandleStateHungup)
+			}
+			msg.decoded = h.onMessage(event.PluginData.Data, &event.Jsep)
+			h.publish(msg)
 		}
 	case "slowlink":
-		logger.Info("handle %s(%d) get event %s uplink %v media %s lost %d", h.tag, h.GetID(), event.Janus, event.Uplink, event.Media, event.Lost)
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus), F("uplink", event.Uplink), F("media", event.Media), F("lost", event.Lost))
+		h.js.cli.metrics.HandleEvent(h.GetID(), h.plugin, event.Janus)
+		h.publish(&SlowLink{eventHeader: header, Media: event.Media, Uplink: event.Uplink, Lost: event.Lost})
 	case "media":
-		logger.Info("handle %s(%d) get event %s %s receiving %v", h.tag, h.GetID(), event.Janus, event.Type, event.Receiving)
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus), F("type", event.Type), F("receiving", event.Receiving))
+		h.js.cli.metrics.HandleEvent(h.GetID(), h.plugin, event.Janus)
+		h.publish(&Media{eventHeader: header, Type: event.Type, Receiving: event.Receiving})
 	case "hangup":
-		logger.Info("handle %s(%d) get event %s reason %s", h.tag, h.GetID(), event.Janus, event.Reason)
-		if h.callBack != nil {
-			h.callBack(h, event.Janus, nil)
-		}
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus), F("reason", event.Reason))
+		h.js.cli.metrics.HandleEvent(h.GetID(), h.plugin, event.Janus)
+		h.stateLock.Lock()
 		h.dataReady = false
 		h.webrtcUp = false
+		h.stateLock.Unlock()
+		h.setState(HandleStateHungup)
+		h.callback(event.Janus, nil)
+		h.publish(&Hangup{eventHeader: header, Reason: event.Reason})
+	case "trickle":
+		if event.Candidate != nil {
+			h.stateLock.Lock()
+			f := h.onCandidate
+			h.stateLock.Unlock()
+			if f != nil {
+				candidate := event.Candidate
+				h.runCallback(func() { f(candidate) })
+			}
+			h.publish(&Trickle{eventHeader: header, Candidate: *event.Candidate})
+		}
 	case "detached":
-		logger.Info("handle %s(%d) get event %s", h.tag, h.GetID(), event.Janus)
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus))
+		h.publish(&Detached{eventHeader: header})
+		h.js.removeHandle(h)
+		h.setState(HandleStateDetached)
+		h.closeEvents()
 	case "dataready":
+		h.stateLock.Lock()
 		h.dataReady = true
+		h.stateLock.Unlock()
 		fallthrough
 	case "webrtcup":
-		logger.Info("handle %s(%d) get event %s", h.tag, h.GetID(), event.Janus)
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus))
 		if event.Janus == "webrtcup" {
+			h.stateLock.Lock()
 			h.webrtcUp = true
+			h.stateLock.Unlock()
+			h.setState(HandleStateWebRTCUp)
 		}
-		if h.callBack != nil {
-			h.callBack(h, event.Janus, nil)
+		h.callback(event.Janus, nil)
+		if event.Janus == "webrtcup" {
+			h.publish(&WebRTCUp{eventHeader: header})
+		} else {
+			h.publish(&DataReady{eventHeader: header})
 		}
 	default:
-		logger.Info("handle %s(%d) get event %s", h.tag, h.GetID(), string(event.oriMsg))
+		h.log(LevelInfo, "handle event", F(FieldEvent, event.Janus), F("message", h.js.payload(event.oriMsg)))
 	}
 }
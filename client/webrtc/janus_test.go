@@ -0,0 +1,170 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client/webrtc/janustest"
+)
+
+func TestSendSyncAndAsync(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "send")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exists VideoRoomResponse
+	if _, err = h.Send(&VideoRoomCommon{Request: "exists", Room: 1234}, nil, &exists); err != nil {
+		t.Fatal(err)
+	}
+	if !exists.Exists {
+		t.Fatal("room 1234 should exist")
+	}
+	var join VideoRoomJoin
+	var joined VideoRoomResponse
+	join.AsPublisher(1234, "alice")
+	resp, err := h.Send(&join, nil, &joined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Janus != "event" || joined.VideoRoom != "joined" || joined.ID == 0 {
+		t.Fatalf("join got %s %s id %d", resp.Janus, joined.VideoRoom, joined.ID)
+	}
+}
+
+func TestSendPluginError(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "send")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var join VideoRoomJoin
+	join.AsPublisher(999, "alice")
+	if _, err = h.Send(&join, nil); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("join missing room got %v, want ErrRoomNotFound", err)
+	}
+	srv.FailNext("message", janustest.ErrHandleNotFound, "No such handle")
+	if _, err = h.Send(&VideoRoomCommon{Request: "list"}, nil); !errors.Is(err, ErrHandleNotFound) {
+		t.Fatalf("failed message got %v, want ErrHandleNotFound", err)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.DropNext("message")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = h.SendContext(ctx, &VideoRoomCommon{Request: "list"}, nil)
+	var te *TimeoutError
+	if !errors.Is(err, ErrTimeout) || !errors.As(err, &te) || te.Request != "message" {
+		t.Fatalf("got %v, want message TimeoutError", err)
+	}
+	// 超时的请求不影响之后的请求
+	if _, err = h.Send(&VideoRoomCommon{Request: "list"}, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAttachCanceled(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	srv.DropNext("attach")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	_, err := js.AttachContext(ctx, PluginVideoRoom, "canceled")
+	var ce *CanceledError
+	if !errors.As(err, &ce) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want CanceledError", err)
+	}
+}
+
+func TestDetachRemovesHandle(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	a, err := js.Attach(PluginVideoRoom, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := js.Attach(PluginSIP, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	ids := srv.Handles(js.GetSessionID())
+	if len(ids) != 1 || ids[0] != b.GetID() {
+		t.Fatalf("server handles %v, want [%d]", ids, b.GetID())
+	}
+	if a.State() != HandleStateDetached {
+		t.Fatalf("detached handle state %s", a.State())
+	}
+	if _, err = a.Send(&VideoRoomCommon{Request: "list"}, nil); !errors.Is(err, ErrHandleNotFound) {
+		t.Fatalf("send on detached handle got %v, want ErrHandleNotFound", err)
+	}
+}
+
+func TestEventRouting(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	a, err := js.Attach(PluginVideoRoom, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := js.Attach(PluginVideoRoom, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callbacks := make(chan string, 4)
+	b.SetEventCallBack(func(_ *Handle, name string, _ interface{}) {
+		callbacks <- name
+	})
+	srv.NotifyPlugin(b.GetID(), map[string]interface{}{"videoroom": "slow_link", "current-bitrate": 64000}, nil)
+	srv.Notify(a.GetID(), "webrtcup", nil)
+	select {
+	case ev := <-a.Events():
+		if _, ok := ev.(*WebRTCUp); !ok || ev.HandleID() != a.GetID() {
+			t.Fatalf("handle a got %T from %d", ev, ev.HandleID())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle a got no event")
+	}
+	select {
+	case ev := <-b.Events():
+		msg, ok := ev.(*PluginMessage)
+		if !ok || msg.VideoRoom() == nil || msg.VideoRoom().CurrentBitrate != 64000 {
+			t.Fatalf("handle b got %T %+v", ev, ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle b got no event")
+	}
+	// 回调在回调协程中调用，不保证先于事件通道
+	select {
+	case name := <-callbacks:
+		if name != "slow_link" {
+			t.Fatalf("callback %s, want slow_link", name)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no callback")
+	}
+	if a.State() != HandleStateWebRTCUp {
+		t.Fatalf("handle a state %s after webrtcup", a.State())
+	}
+	select {
+	case ev := <-a.Events():
+		t.Fatalf("handle a got unexpected %T", ev)
+	default:
+	}
+}
@@ -14,29 +14,29 @@ type Error interface {
 	Reason() string
 }
 
-type eventAck struct {
-	done  chan struct{}  // 响应到达
-	id    string         // transaction id
-	value *JanusResponse // ack struct
-	// ts    time.Time      // 响应时间
-}
-
-func newEventAck(id string) *eventAck {
-	return &eventAck{
-		done: make(chan struct{}),
-		id:   id,
-	}
+// eventWaiter 等待 handle 上的某个事件
+type eventWaiter struct {
+	name string
+	ch   chan *JanusResponse
 }
 
 type janusRequest struct {
-	Janus       string      `json:"janus,omitempty"`
-	Transaction string      `json:"transaction,omitempty"`
-	APISecret   string      `json:"apisecret,omitempty"`
-	SessionID   int64       `json:"session_id,omitempty"`
-	HandleID    int64       `json:"handle_id,omitempty"`
-	Plugin      string      `json:"plugin,omitempty"`
-	Body        interface{} `json:"body,omitempty"`
-	Jsep        *Jsep       `json:"jsep,omitempty"`
+	Janus       string        `json:"janus,omitempty"`
+	Transaction string        `json:"transaction,omitempty"`
+	APISecret   string        `json:"apisecret,omitempty"`
+	SessionID   int64         `json:"session_id,omitempty"`
+	HandleID    int64         `json:"handle_id,omitempty"`
+	Plugin      string        `json:"plugin,omitempty"`
+	Body        interface{}   `json:"body,omitempty"`
+	Jsep        *Jsep         `json:"jsep,omitempty"`
+	Candidate   *ICECandidate `json:"candidate,omitempty"`
+	// admin/monitor 请求字段
+	AdminSecret string   `json:"admin_secret,omitempty"`
+	Level       *int     `json:"level,omitempty"`
+	Token       string   `json:"token,omitempty"`
+	Plugins     []string `json:"plugins,omitempty"`
+	Accept      *bool    `json:"accept,omitempty"`
+	admin       bool     // admin 接口请求，Token 为要管理的 token，不填充认证字段
 }
 
 // JSON json show
@@ -44,10 +44,19 @@ func (jr janusRequest) JSON() (out string) {
 	return client.ShowJSON(&jr)
 }
 
+// String 请求摘要，隐藏 apisecret 和 SDP
 func (jr janusRequest) String() (out string) {
+	return jr.format(false)
+}
+
+// format 请求摘要，trace 为 true 时输出 apisecret 和完整 SDP
+func (jr janusRequest) format(trace bool) (out string) {
 	var fields []string
 	fields = append(fields, fmt.Sprintf("janus %s", jr.Janus))
 	fields = append(fields, fmt.Sprintf("transaction %s", jr.Transaction))
+	if trace && jr.APISecret != "" {
+		fields = append(fields, fmt.Sprintf("apisecret %s", jr.APISecret))
+	}
 	if jr.SessionID != 0 {
 		fields = append(fields, fmt.Sprintf("session %d", jr.SessionID))
 	}
@@ -58,21 +67,27 @@ func (jr janusRequest) String() (out string) {
 		fields = append(fields, fmt.Sprintf("plugin %s", jr.Plugin))
 	} else if jr.Janus == "message" {
 		body := jr.Body
+		vv := client.ShowJSON(body, true)
+		if !trace {
+			vv = string(redactJSON([]byte(vv)))
+		}
 		if reg, ok := body.(SIPRegister); ok {
 			if reg.Type == "helper" {
 				fields = append(fields, " register helper")
 			} else {
-				vv := client.ShowJSON(body, true)
-				fields = append(fields, string(vv))
+				fields = append(fields, vv)
 			}
 		} else {
-			vv := client.ShowJSON(body, true)
-			fields = append(fields, "body "+string(vv))
+			fields = append(fields, "body "+vv)
 		}
 	}
 	if jr.Jsep != nil {
-		vv := client.ShowJSON(jr.Jsep, true)
-		fields = append(fields, "Jsep "+string(vv))
+		if trace {
+			vv := client.ShowJSON(jr.Jsep, true)
+			fields = append(fields, "Jsep "+string(vv))
+		} else {
+			fields = append(fields, fmt.Sprintf("Jsep %s(sdp %d bytes)", jr.Jsep.Type, len(jr.Jsep.SDP)))
+		}
 	}
 	out = strings.Join(fields, ",")
 	return
@@ -94,6 +109,7 @@ type JanusResponse struct {
 	Data        json.RawMessage `json:"data,omitempty"`
 	Jsep        Jsep            `json:"jsep,omitempty"`
 	Error       *respError      `json:"error,omitempty"`
+	Candidate   *ICECandidate   `json:"candidate,omitempty"` // trickle
 	oriMsg      []byte
 }
 
@@ -114,12 +130,13 @@ func (resp *JanusResponse) HasError(infos ...string) (err error) {
 		if resp.Error != nil {
 			err = NewError(resp.Error.Code, resp.Error.Reason, infos...)
 		} else {
-			err = NewError(-1, "unknown", infos...)
+			err = NewError(ErrCodeUnknown, "unknown", infos...)
 		}
-	} else if resp.Janus == "event" && resp.PluginData != nil && resp.PluginData.Data != nil {
+	} else if (resp.Janus == "event" || resp.Janus == "success") && resp.PluginData != nil && resp.PluginData.Data != nil {
+		// 插件同步请求的错误在 success 响应的 plugindata 中
 		var pluginError PluginRespError
 		if json.Unmarshal(resp.PluginData.Data, &pluginError) == nil && pluginError.InErrorCode != 0 {
-			err = NewError(pluginError.InErrorCode, pluginError.InError, infos...)
+			err = NewPluginError(resp.PluginData.Plugin, pluginError.InErrorCode, pluginError.InError, infos...)
 		}
 	}
 	return
@@ -136,8 +153,10 @@ type janusPlugin struct {
 	Data   json.RawMessage `json:"data,omitempty"`
 }
 
+// PluginRespError janus 或插件返回的错误，Plugin 为空表示 janus 核心错误
 type PluginRespError struct {
 	Info        string
+	Plugin      string `json:"-"`
 	InErrorCode int    `json:"error_code"`
 	InError     string `json:"error"`
 }
@@ -154,10 +173,24 @@ func (pre *PluginRespError) Reason() string {
 	return pre.InError
 }
 
+// Is 支持 errors.Is 判断错误类型，如 403 对应 ErrUnauthorized，videoroom 426 对应 ErrRoomNotFound
+func (pre *PluginRespError) Is(target error) bool {
+	if ce, ok := target.(*codeError); ok {
+		return ce.match(pre.Plugin, pre.InErrorCode)
+	}
+	return false
+}
+
+// NewError janus 核心错误
 func NewError(code int, reason string, infos ...string) error {
 	return &PluginRespError{Info: strings.Join(infos, " "), InErrorCode: code, InError: reason}
 }
 
+// NewPluginError 插件返回的错误，plugin 为插件名，如 PluginVideoRoom
+func NewPluginError(plugin string, code int, reason string, infos ...string) error {
+	return &PluginRespError{Info: strings.Join(infos, " "), Plugin: plugin, InErrorCode: code, InError: reason}
+}
+
 // Jsep janus sdp
 type Jsep struct {
 	Type    string `json:"type,omitempty"`
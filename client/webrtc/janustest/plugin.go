@@ -0,0 +1,96 @@
+package janustest
+
+import (
+	"encoding/json"
+)
+
+// support plugin name
+const (
+	PluginSIP       = "janus.plugin.sip"
+	PluginVideoRoom = "janus.plugin.videoroom"
+)
+
+// janus 核心错误码
+const (
+	ErrUnauthorized    = 403
+	ErrUnknownRequest  = 453
+	ErrSessionNotFound = 458
+	ErrHandleNotFound  = 459
+	ErrPluginNotFound  = 460
+	ErrMissingElement  = 456
+	ErrTokenNotFound   = 470
+)
+
+// FakeSDP 模拟 offer/answer 使用的 SDP
+const FakeSDP = "v=0\r\no=- 0 0 IN IP4 127.0.0.1\r\ns=janustest\r\nt=0 0\r\n"
+
+// Message 插件收到的消息
+type Message struct {
+	*Request
+	State map[string]interface{} // handle 上保存的插件状态，同一 handle 的消息共享
+}
+
+// Decode 解析消息 body
+func (m *Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Body, v)
+}
+
+// RequestName body 中的 request 字段
+func (m *Message) RequestName() string {
+	var body struct {
+		Request string `json:"request"`
+	}
+	m.Decode(&body)
+	return body.Request
+}
+
+// HasJsep 消息是否携带 jsep
+func (m *Message) HasJsep() bool {
+	return len(m.Jsep) > 0 && string(m.Jsep) != "null"
+}
+
+// PluginEvent 插件事件内容
+type PluginEvent struct {
+	Data interface{}
+	Jsep interface{}
+}
+
+// Reply 插件对消息的响应方式
+type Reply struct {
+	Data       interface{}   // 第一个事件的 plugindata.data，Sync 时为同步响应内容
+	Jsep       interface{}   // 第一个事件携带的 jsep
+	More       []interface{} // 同一 transaction 的后续事件
+	Then       []PluginEvent // 之后不带 transaction 的事件
+	Sync       bool          // 同步响应，直接返回 success
+	EventFirst bool          // 先发 event 再发 ack
+	NoAck      bool          // 不发 ack
+	Drop       bool          // 不响应，用于测试超时
+}
+
+// PluginError 插件错误响应内容
+func PluginError(name string, code int, reason string) map[string]interface{} {
+	return map[string]interface{}{name: "event", "error_code": code, "error": reason}
+}
+
+// Plugin 模拟插件
+type Plugin interface {
+	HandleMessage(s *Server, msg *Message) Reply
+}
+
+// PluginFunc 函数形式的插件
+type PluginFunc func(s *Server, msg *Message) Reply
+
+// HandleMessage implements Plugin
+func (f PluginFunc) HandleMessage(s *Server, msg *Message) Reply {
+	return f(s, msg)
+}
+
+// Detacher 插件需要在 handle 解绑时清理状态时实现
+type Detacher interface {
+	Detach(s *Server, handleID int64, state map[string]interface{})
+}
+
+// jsepOf 构造 jsep
+func jsepOf(typ string) map[string]interface{} {
+	return map[string]interface{}{"type": typ, "sdp": FakeSDP}
+}
@@ -0,0 +1,638 @@
+// Package janustest 进程内的 janus 模拟服务器，用于离线测试 webrtc 包
+//
+// 服务器实现 janus WebSocket 协议的 create、attach、message、keepalive、trickle、hangup、detach、destroy、claim 和 admin 接口的 add_token、remove_token，
+// 插件消息由注册的 Plugin 处理，可模拟 ack 后再回 event、先 event 后 ack、错误和不响应等情况。
+package janustest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Request 服务器收到的请求
+type Request struct {
+	Janus       string          `json:"janus"`
+	Transaction string          `json:"transaction,omitempty"`
+	SessionID   int64           `json:"session_id,omitempty"`
+	HandleID    int64           `json:"handle_id,omitempty"`
+	Plugin      string          `json:"plugin,omitempty"`
+	APISecret   string          `json:"apisecret,omitempty"`
+	Token       string          `json:"token,omitempty"`
+	Plugins     []string        `json:"plugins,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+	Jsep        json.RawMessage `json:"jsep,omitempty"`
+	Candidate   json.RawMessage `json:"candidate,omitempty"`
+	Candidates  json.RawMessage `json:"candidates,omitempty"`
+	Raw         json.RawMessage `json:"-"`
+}
+
+// Hook 拦截请求，返回 true 表示已处理，不再走默认逻辑
+type Hook func(c *Conn, req *Request) bool
+
+// Server janus 模拟服务器
+type Server struct {
+	hs      *httptest.Server
+	lock    sync.Mutex
+	nextID  int64
+	plugins map[string]Plugin
+	hooks   map[string][]Hook
+	// 会话和 handle
+	sessions map[int64]*session
+	handles  map[int64]*handle
+	conns    map[*Conn]bool
+	requests []*Request
+	secret   string
+	tokens   map[string]bool
+}
+
+type session struct {
+	id      int64
+	conn    *Conn
+	handles map[int64]*handle
+}
+
+type handle struct {
+	id      int64
+	session *session
+	plugin  string
+	lock    sync.Mutex             // 保护 state
+	state   map[string]interface{} // 插件保存的状态
+}
+
+// Conn 客户端连接
+type Conn struct {
+	ws    *websocket.Conn
+	wlock sync.Mutex
+	srv   *Server
+}
+
+// Send 发送一条消息给客户端
+func (c *Conn) Send(v interface{}) (err error) {
+	var msg []byte
+	if msg, err = json.Marshal(v); err != nil {
+		return
+	}
+	c.wlock.Lock()
+	err = c.ws.WriteMessage(websocket.TextMessage, msg)
+	c.wlock.Unlock()
+	return
+}
+
+// Close 断开连接，用于模拟网络中断
+func (c *Conn) Close() error {
+	return c.ws.Close()
+}
+
+// NewServer 启动模拟服务器，默认注册 videoroom 和 sip 插件
+func NewServer() (s *Server) {
+	s = &Server{
+		nextID:   1000,
+		plugins:  make(map[string]Plugin),
+		hooks:    make(map[string][]Hook),
+		sessions: make(map[int64]*session),
+		handles:  make(map[int64]*handle),
+		conns:    make(map[*Conn]bool),
+		tokens:   make(map[string]bool),
+	}
+	s.RegisterPlugin(PluginVideoRoom, NewVideoRoom())
+	s.RegisterPlugin(PluginSIP, NewSIP())
+	s.hs = httptest.NewServer(http.HandlerFunc(s.serveWS))
+	return
+}
+
+// URL WebSocket 地址，可直接用于 webrtc.NewClient
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.hs.URL, "http")
+}
+
+// Close 关闭服务器和所有连接
+func (s *Server) Close() {
+	s.DropConnections()
+	s.hs.Close()
+}
+
+// SetSecret 要求请求携带 apisecret，错误时返回 403
+func (s *Server) SetSecret(secret string) {
+	s.lock.Lock()
+	s.secret = secret
+	s.lock.Unlock()
+}
+
+// AddToken 添加允许的 token，设置了 token 后请求必须携带有效 token
+func (s *Server) AddToken(token string) {
+	s.lock.Lock()
+	s.tokens[token] = true
+	s.lock.Unlock()
+}
+
+// RemoveToken 删除 token，之后使用该 token 的请求返回 403
+func (s *Server) RemoveToken(token string) {
+	s.lock.Lock()
+	delete(s.tokens, token)
+	s.lock.Unlock()
+}
+
+// RegisterPlugin 注册插件
+func (s *Server) RegisterPlugin(name string, p Plugin) {
+	s.lock.Lock()
+	s.plugins[name] = p
+	s.lock.Unlock()
+}
+
+// OnRequest 注册请求拦截，verb 为 janus 请求类型，如 message、keepalive，"*" 拦截所有请求
+func (s *Server) OnRequest(verb string, h Hook) {
+	s.lock.Lock()
+	s.hooks[verb] = append(s.hooks[verb], h)
+	s.lock.Unlock()
+}
+
+// FailNext 下一个 verb 请求返回 janus 错误
+func (s *Server) FailNext(verb string, code int, reason string) {
+	var once sync.Once
+	s.OnRequest(verb, func(c *Conn, req *Request) (handled bool) {
+		once.Do(func() {
+			c.Send(ErrorResponse(req, code, reason))
+			handled = true
+		})
+		return
+	})
+}
+
+// DropNext 下一个 verb 请求不响应，用于测试超时
+func (s *Server) DropNext(verb string) {
+	var once sync.Once
+	s.OnRequest(verb, func(c *Conn, req *Request) (handled bool) {
+		once.Do(func() {
+			handled = true
+		})
+		return
+	})
+}
+
+// Requests 返回收到的所有请求
+func (s *Server) Requests() (reqs []*Request) {
+	s.lock.Lock()
+	reqs = append(reqs, s.requests...)
+	s.lock.Unlock()
+	return
+}
+
+// CountRequests 统计某类请求的数量
+func (s *Server) CountRequests(verb string) (n int) {
+	for _, req := range s.Requests() {
+		if req.Janus == verb {
+			n++
+		}
+	}
+	return
+}
+
+// DropConnections 断开所有客户端连接，会话保留，可通过 claim 接管
+func (s *Server) DropConnections() {
+	s.lock.Lock()
+	var conns []*Conn
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.lock.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// ExpireSession 删除会话，模拟服务器端会话超时，并向客户端发送 timeout 事件
+func (s *Server) ExpireSession(sessionID int64) {
+	s.lock.Lock()
+	sess := s.sessions[sessionID]
+	if sess != nil {
+		delete(s.sessions, sessionID)
+		for id := range sess.handles {
+			delete(s.handles, id)
+		}
+	}
+	s.lock.Unlock()
+	if sess != nil && sess.conn != nil {
+		sess.conn.Send(map[string]interface{}{"janus": "timeout", "session_id": sessionID})
+	}
+}
+
+// Sessions 当前会话 ID
+func (s *Server) Sessions() (ids []int64) {
+	s.lock.Lock()
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	s.lock.Unlock()
+	return
+}
+
+// Handles 会话中的 handle ID
+func (s *Server) Handles(sessionID int64) (ids []int64) {
+	s.lock.Lock()
+	if sess := s.sessions[sessionID]; sess != nil {
+		for id := range sess.handles {
+			ids = append(ids, id)
+		}
+	}
+	s.lock.Unlock()
+	return
+}
+
+// Notify 向 handle 推送核心事件，如 webrtcup、media、slowlink、hangup，fields 为附加字段
+func (s *Server) Notify(handleID int64, janus string, fields map[string]interface{}) error {
+	var msg = map[string]interface{}{}
+	for k, v := range fields {
+		msg[k] = v
+	}
+	msg["janus"] = janus
+	return s.sendToHandle(handleID, msg)
+}
+
+// NotifyPlugin 向 handle 推送插件事件
+func (s *Server) NotifyPlugin(handleID int64, data interface{}, jsep interface{}) error {
+	s.lock.Lock()
+	h := s.handles[handleID]
+	s.lock.Unlock()
+	if h == nil {
+		return fmt.Errorf("no such handle %d", handleID)
+	}
+	return s.sendToHandle(handleID, pluginEvent(h, "", data, jsep))
+}
+
+func (s *Server) sendToHandle(handleID int64, msg map[string]interface{}) error {
+	s.lock.Lock()
+	h := s.handles[handleID]
+	var conn *Conn
+	if h != nil {
+		conn = h.session.conn
+		msg["session_id"] = h.session.id
+		msg["sender"] = h.id
+	}
+	s.lock.Unlock()
+	if h == nil {
+		return fmt.Errorf("no such handle %d", handleID)
+	}
+	if conn == nil {
+		return fmt.Errorf("handle %d session not connected", handleID)
+	}
+	return conn.Send(msg)
+}
+
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{"janus-protocol", "janus-admin-protocol"},
+	CheckOrigin:  func(r *http.Request) bool { return true },
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := &Conn{ws: ws, srv: s}
+	s.lock.Lock()
+	s.conns[c] = true
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, c)
+		for _, sess := range s.sessions {
+			if sess.conn == c {
+				sess.conn = nil
+			}
+		}
+		s.lock.Unlock()
+		ws.Close()
+	}()
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		req := new(Request)
+		if json.Unmarshal(msg, req) != nil {
+			continue
+		}
+		req.Raw = msg
+		s.handle(c, req)
+	}
+}
+
+func (s *Server) handle(c *Conn, req *Request) {
+	s.lock.Lock()
+	s.requests = append(s.requests, req)
+	hooks := append(append([]Hook{}, s.hooks["*"]...), s.hooks[req.Janus]...)
+	s.lock.Unlock()
+	for _, h := range hooks {
+		if h(c, req) {
+			return
+		}
+	}
+	if req.Janus == "info" {
+		c.Send(s.serverInfo(req))
+		return
+	}
+	if req.Janus == "add_token" || req.Janus == "remove_token" {
+		s.adminToken(c, req)
+		return
+	}
+	if code, reason := s.authorize(req); code != 0 {
+		c.Send(ErrorResponse(req, code, reason))
+		return
+	}
+	switch req.Janus {
+	case "create":
+		s.lock.Lock()
+		sess := &session{id: s.newID(), conn: c, handles: make(map[int64]*handle)}
+		s.sessions[sess.id] = sess
+		s.lock.Unlock()
+		c.Send(successResponse(req, map[string]interface{}{"id": sess.id}))
+	case "claim":
+		s.lock.Lock()
+		sess := s.sessions[req.SessionID]
+		if sess != nil {
+			sess.conn = c
+		}
+		s.lock.Unlock()
+		if sess == nil {
+			c.Send(ErrorResponse(req, ErrSessionNotFound, fmt.Sprintf("No such session %d", req.SessionID)))
+			return
+		}
+		c.Send(successResponse(req, nil))
+	case "keepalive":
+		if s.session(req) == nil {
+			c.Send(ErrorResponse(req, ErrSessionNotFound, fmt.Sprintf("No such session %d", req.SessionID)))
+			return
+		}
+		c.Send(ackResponse(req))
+	case "destroy":
+		s.lock.Lock()
+		sess := s.sessions[req.SessionID]
+		if sess != nil {
+			delete(s.sessions, sess.id)
+			for id := range sess.handles {
+				delete(s.handles, id)
+			}
+		}
+		s.lock.Unlock()
+		if sess == nil {
+			c.Send(ErrorResponse(req, ErrSessionNotFound, fmt.Sprintf("No such session %d", req.SessionID)))
+			return
+		}
+		c.Send(successResponse(req, nil))
+	case "attach":
+		s.attach(c, req)
+	default:
+		s.handleRequest(c, req)
+	}
+}
+
+// serverInfo info 请求不需要鉴权，插件列表为已注册的插件
+func (s *Server) serverInfo(req *Request) map[string]interface{} {
+	s.lock.Lock()
+	plugins := make(map[string]interface{}, len(s.plugins))
+	for name := range s.plugins {
+		plugins[name] = map[string]interface{}{"name": name, "version_string": "0.0.1", "version": 1}
+	}
+	secret := s.secret != ""
+	token := len(s.tokens) > 0
+	s.lock.Unlock()
+	return map[string]interface{}{
+		"janus":                  "server_info",
+		"transaction":            req.Transaction,
+		"name":                   "Janus WebRTC Server",
+		"version":                1000,
+		"version_string":         "1.0.0",
+		"author":                 "janustest",
+		"server-name":            "janustest",
+		"data_channels":          true,
+		"accepting-new-sessions": true,
+		"session-timeout":        60,
+		"full-trickle":           true,
+		"api_secret":             secret,
+		"auth_token":             token,
+		"transports": map[string]interface{}{
+			"janus.transport.websockets": map[string]interface{}{"name": "JANUS WebSockets transport plugin", "version_string": "0.0.1", "version": 1},
+		},
+		"plugins": plugins,
+	}
+}
+
+// adminToken admin 接口的 add_token 和 remove_token，不检查 admin_secret
+func (s *Server) adminToken(c *Conn, req *Request) {
+	if req.Token == "" {
+		c.Send(ErrorResponse(req, ErrMissingElement, "Missing mandatory element (token)"))
+		return
+	}
+	s.lock.Lock()
+	_, found := s.tokens[req.Token]
+	if req.Janus == "add_token" {
+		s.tokens[req.Token] = true
+	} else {
+		delete(s.tokens, req.Token)
+	}
+	s.lock.Unlock()
+	if req.Janus == "remove_token" {
+		if !found {
+			c.Send(ErrorResponse(req, ErrTokenNotFound, fmt.Sprintf("Token %s not found", req.Token)))
+			return
+		}
+		c.Send(successResponse(req, nil))
+		return
+	}
+	plugins := req.Plugins
+	if len(plugins) == 0 {
+		s.lock.Lock()
+		for name := range s.plugins {
+			plugins = append(plugins, name)
+		}
+		s.lock.Unlock()
+		sort.Strings(plugins)
+	}
+	c.Send(successResponse(req, map[string]interface{}{"plugins": plugins}))
+}
+
+func (s *Server) authorize(req *Request) (code int, reason string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.secret != "" && req.APISecret != s.secret {
+		return ErrUnauthorized, "Unauthorized request (wrong or missing secret/token)"
+	}
+	if len(s.tokens) > 0 && !s.tokens[req.Token] {
+		return ErrUnauthorized, "Unauthorized request (wrong or missing secret/token)"
+	}
+	return
+}
+
+func (s *Server) session(req *Request) (sess *session) {
+	s.lock.Lock()
+	sess = s.sessions[req.SessionID]
+	s.lock.Unlock()
+	return
+}
+
+func (s *Server) attach(c *Conn, req *Request) {
+	s.lock.Lock()
+	sess := s.sessions[req.SessionID]
+	_, ok := s.plugins[req.Plugin]
+	var h *handle
+	if sess != nil && ok {
+		h = &handle{id: s.newID(), session: sess, plugin: req.Plugin, state: make(map[string]interface{})}
+		sess.handles[h.id] = h
+		s.handles[h.id] = h
+	}
+	s.lock.Unlock()
+	switch {
+	case sess == nil:
+		c.Send(ErrorResponse(req, ErrSessionNotFound, fmt.Sprintf("No such session %d", req.SessionID)))
+	case !ok:
+		c.Send(ErrorResponse(req, ErrPluginNotFound, fmt.Sprintf("No such plugin '%s'", req.Plugin)))
+	default:
+		c.Send(successResponse(req, map[string]interface{}{"id": h.id}))
+	}
+}
+
+// handleRequest 需要 handle 的请求
+func (s *Server) handleRequest(c *Conn, req *Request) {
+	s.lock.Lock()
+	sess := s.sessions[req.SessionID]
+	var h *handle
+	var p Plugin
+	if sess != nil {
+		h = sess.handles[req.HandleID]
+	}
+	if h != nil {
+		p = s.plugins[h.plugin]
+	}
+	s.lock.Unlock()
+	if sess == nil {
+		c.Send(ErrorResponse(req, ErrSessionNotFound, fmt.Sprintf("No such session %d", req.SessionID)))
+		return
+	}
+	if h == nil {
+		c.Send(ErrorResponse(req, ErrHandleNotFound, fmt.Sprintf("No such handle %d in session %d", req.HandleID, req.SessionID)))
+		return
+	}
+	switch req.Janus {
+	case "message":
+		h.lock.Lock()
+		reply := p.HandleMessage(s, &Message{Request: req, State: h.state})
+		h.lock.Unlock()
+		s.reply(c, h, req, reply)
+	case "trickle":
+		c.Send(ackResponse(req))
+	case "hangup":
+		c.Send(successResponse(req, nil))
+		c.Send(map[string]interface{}{"janus": "hangup", "session_id": sess.id, "sender": h.id, "reason": "Janus API"})
+	case "detach":
+		s.lock.Lock()
+		delete(sess.handles, h.id)
+		delete(s.handles, h.id)
+		s.lock.Unlock()
+		if d, ok := p.(Detacher); ok {
+			h.lock.Lock()
+			d.Detach(s, h.id, h.state)
+			h.lock.Unlock()
+		}
+		c.Send(successResponse(req, nil))
+		c.Send(map[string]interface{}{"janus": "detached", "session_id": sess.id, "sender": h.id})
+	default:
+		c.Send(ErrorResponse(req, ErrUnknownRequest, fmt.Sprintf("Unknown request '%s'", req.Janus)))
+	}
+}
+
+// reply 按插件响应的方式回复消息
+func (s *Server) reply(c *Conn, h *handle, req *Request, reply Reply) {
+	if reply.Drop {
+		return
+	}
+	defer func() {
+		for _, ev := range reply.Then {
+			c.Send(pluginEvent(h, "", ev.Data, ev.Jsep))
+		}
+	}()
+	if reply.Sync {
+		msg := successResponse(req, nil)
+		msg["sender"] = h.id
+		msg["plugindata"] = map[string]interface{}{"plugin": h.plugin, "data": reply.Data}
+		c.Send(msg)
+		return
+	}
+	var events []map[string]interface{}
+	if reply.Data != nil {
+		events = append(events, pluginEvent(h, req.Transaction, reply.Data, reply.Jsep))
+	}
+	for _, more := range reply.More {
+		events = append(events, pluginEvent(h, req.Transaction, more, nil))
+	}
+	if reply.EventFirst {
+		for _, ev := range events {
+			c.Send(ev)
+		}
+		if !reply.NoAck {
+			c.Send(ackResponse(req))
+		}
+		return
+	}
+	if !reply.NoAck {
+		c.Send(ackResponse(req))
+	}
+	for _, ev := range events {
+		c.Send(ev)
+	}
+}
+
+// ErrorResponse 构造 janus 错误响应
+func ErrorResponse(req *Request, code int, reason string) map[string]interface{} {
+	msg := map[string]interface{}{
+		"janus":       "error",
+		"transaction": req.Transaction,
+		"error":       map[string]interface{}{"code": code, "reason": reason},
+	}
+	if req.SessionID != 0 {
+		msg["session_id"] = req.SessionID
+	}
+	return msg
+}
+
+func successResponse(req *Request, data interface{}) map[string]interface{} {
+	msg := map[string]interface{}{"janus": "success", "transaction": req.Transaction}
+	if req.SessionID != 0 {
+		msg["session_id"] = req.SessionID
+	}
+	if data != nil {
+		msg["data"] = data
+	}
+	return msg
+}
+
+func ackResponse(req *Request) map[string]interface{} {
+	return map[string]interface{}{"janus": "ack", "session_id": req.SessionID, "transaction": req.Transaction}
+}
+
+func pluginEvent(h *handle, transaction string, data, jsep interface{}) map[string]interface{} {
+	msg := map[string]interface{}{
+		"janus":      "event",
+		"session_id": h.session.id,
+		"sender":     h.id,
+		"plugindata": map[string]interface{}{"plugin": h.plugin, "data": data},
+	}
+	if transaction != "" {
+		msg["transaction"] = transaction
+	}
+	if jsep != nil {
+		msg["jsep"] = jsep
+	}
+	return msg
+}
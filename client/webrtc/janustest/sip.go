@@ -0,0 +1,148 @@
+package janustest
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// sip 插件错误码
+const (
+	SIPErrInvalidRequest    = 442
+	SIPErrMissingElement    = 443
+	SIPErrAlreadyRegistered = 445
+	SIPErrWrongState        = 447
+	SIPErrNoSuchCallID      = 454
+)
+
+// SIP 模拟 sip 插件，支持注册、呼叫、接听、挂断和 dtmf_info
+type SIP struct {
+	callSeq int64
+}
+
+// NewSIP 创建模拟 sip 插件
+func NewSIP() *SIP {
+	return new(SIP)
+}
+
+type sipBody struct {
+	Request  string `json:"request"`
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	URI      string `json:"uri"`
+	CallID   string `json:"call_id"`
+	Code     int    `json:"code"`
+	Digit    string `json:"digit"`
+}
+
+func sipResult(callID, event string, fields map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{"event": event}
+	for k, v := range fields {
+		result[k] = v
+	}
+	data := map[string]interface{}{"sip": "event", "result": result}
+	if callID != "" {
+		data["call_id"] = callID
+	}
+	return data
+}
+
+// HandleMessage implements Plugin
+func (sp *SIP) HandleMessage(s *Server, msg *Message) (reply Reply) {
+	var body sipBody
+	if err := msg.Decode(&body); err != nil {
+		return Reply{Data: PluginError("sip", 441, err.Error())}
+	}
+	registered, _ := msg.State["registered"].(string)
+	callID, _ := msg.State["call_id"].(string)
+	switch body.Request {
+	case "register":
+		if body.Username == "" && body.Type != "guest" {
+			reply.Data = PluginError("sip", SIPErrMissingElement, "Missing element (username)")
+			return
+		}
+		if registered != "" {
+			reply.Data = PluginError("sip", SIPErrAlreadyRegistered, fmt.Sprintf("Already registered (%s)", registered))
+			return
+		}
+		msg.State["registered"] = body.Username
+		reply.Data = sipResult("", "registering", nil)
+		reply.Then = []PluginEvent{{Data: sipResult("", "registered", map[string]interface{}{"username": body.Username, "register_sent": true})}}
+	case "unregister":
+		if registered == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (not registered)")
+			return
+		}
+		delete(msg.State, "registered")
+		reply.Data = sipResult("", "unregistering", nil)
+		reply.Then = []PluginEvent{{Data: sipResult("", "unregistered", map[string]interface{}{"username": registered, "register_sent": true})}}
+	case "call":
+		if registered == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (register first)")
+			return
+		}
+		if callID != "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (already in a call)")
+			return
+		}
+		if body.CallID == "" {
+			body.CallID = fmt.Sprintf("janustest-%d", atomic.AddInt64(&sp.callSeq, 1))
+		}
+		msg.State["call_id"] = body.CallID
+		reply.Data = sipResult(body.CallID, "calling", nil)
+		reply.More = []interface{}{sipResult(body.CallID, "ringing", nil)}
+		reply.Then = []PluginEvent{{Data: sipResult(body.CallID, "accepted", map[string]interface{}{"username": body.URI}), Jsep: jsepOf("answer")}}
+	case "accept":
+		if callID == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (no incoming call)")
+			return
+		}
+		reply.Data = sipResult(callID, "accepting", nil)
+		reply.Then = []PluginEvent{{Data: sipResult(callID, "accepted", nil)}}
+	case "decline":
+		if callID == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (no incoming call)")
+			return
+		}
+		delete(msg.State, "call_id")
+		reply.Data = sipResult(callID, "declining", map[string]interface{}{"code": body.Code})
+		reply.Then = []PluginEvent{{Data: sipResult(callID, "hangup", map[string]interface{}{"code": 486, "reason": "Busy Here"})}}
+	case "hangup":
+		if callID == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (no call)")
+			return
+		}
+		delete(msg.State, "call_id")
+		reply.Data = sipResult(callID, "hangingup", nil)
+		reply.Then = []PluginEvent{{Data: sipResult(callID, "hangup", map[string]interface{}{"code": 200, "reason": "Session Terminated"})}}
+	case "dtmf_info":
+		if callID == "" {
+			reply.Data = PluginError("sip", SIPErrWrongState, "Wrong state (no call)")
+			return
+		}
+		if body.Digit == "" {
+			reply.Data = PluginError("sip", SIPErrMissingElement, "Missing element (digit)")
+			return
+		}
+		reply.Data = sipResult(callID, "dtmfsent", nil)
+	default:
+		reply.Data = PluginError("sip", SIPErrInvalidRequest, fmt.Sprintf("Unknown request (%s)", body.Request))
+	}
+	return
+}
+
+// IncomingCall 模拟来电，向 handle 推送带 offer 的 incomingcall 事件，返回 call_id
+func (sp *SIP) IncomingCall(s *Server, handleID int64, from string) (callID string, err error) {
+	callID = fmt.Sprintf("janustest-in-%d", atomic.AddInt64(&sp.callSeq, 1))
+	s.lock.Lock()
+	h := s.handles[handleID]
+	s.lock.Unlock()
+	if h == nil {
+		err = fmt.Errorf("no such handle %d", handleID)
+		return
+	}
+	h.lock.Lock()
+	h.state["call_id"] = callID
+	h.lock.Unlock()
+	err = s.NotifyPlugin(handleID, sipResult(callID, "incomingcall", map[string]interface{}{"username": from, "displayname": from}), jsepOf("offer"))
+	return
+}
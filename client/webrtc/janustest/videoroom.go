@@ -0,0 +1,563 @@
+package janustest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// videoroom 插件错误码
+const (
+	VideoRoomErrInvalidRequest = 423
+	VideoRoomErrJoinFirst      = 424
+	VideoRoomErrAlreadyJoined  = 425
+	VideoRoomErrNoSuchRoom     = 426
+	VideoRoomErrRoomExists     = 427
+	VideoRoomErrNoSuchFeed     = 428
+	VideoRoomErrUnauthorized   = 433
+	VideoRoomErrNotPublished   = 435
+)
+
+// VideoRoom 模拟 videoroom 插件，支持会议室管理、发布、订阅和参与者事件通知
+type VideoRoom struct {
+	lock   sync.Mutex
+	nextID int64
+	rooms  map[int64]*Room
+}
+
+// Room 模拟会议室
+type Room struct {
+	ID           int64
+	Description  string
+	Secret       string
+	Pin          string
+	IsPrivate    bool
+	Allowed      []string
+	participants map[int64]*participant
+}
+
+type participant struct {
+	id         int64
+	display    string
+	handleID   int64
+	published  bool
+	audioCodec string
+	videoCodec string
+	talking    bool
+}
+
+// NewVideoRoom 创建模拟 videoroom 插件，默认有会议室 1234
+func NewVideoRoom() *VideoRoom {
+	vr := &VideoRoom{nextID: 5000, rooms: make(map[int64]*Room)}
+	vr.AddRoom(&Room{ID: 1234, Description: "Demo Room"})
+	return vr
+}
+
+// AddRoom 添加会议室
+func (vr *VideoRoom) AddRoom(room *Room) {
+	vr.lock.Lock()
+	room.participants = make(map[int64]*participant)
+	vr.rooms[room.ID] = room
+	vr.lock.Unlock()
+}
+
+// Publishers 会议室中已发布的参与者 ID
+func (vr *VideoRoom) Publishers(roomID int64) (ids []int64) {
+	vr.lock.Lock()
+	defer vr.lock.Unlock()
+	if room := vr.rooms[roomID]; room != nil {
+		for id, p := range room.participants {
+			if p.published {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return
+}
+
+type vrBody struct {
+	Request     string     `json:"request"`
+	Room        int64      `json:"room"`
+	Ptype       string     `json:"ptype"`
+	ID          int64      `json:"id"`
+	Feed        int64      `json:"feed"`
+	Display     *string    `json:"display"`
+	Description string     `json:"description"`
+	Secret      string     `json:"secret"`
+	Pin         string     `json:"pin"`
+	IsPrivate   bool       `json:"is_private"`
+	Allowed     []string   `json:"allowed"`
+	Action      string     `json:"action"`
+	AudioCodec  string     `json:"audiocodec"`
+	VideoCodec  string     `json:"videocodec"`
+	NewDesc     string     `json:"new_description"`
+	NewSecret   string     `json:"new_secret"`
+	NewPin      string     `json:"new_pin"`
+	MuteAudio   *bool      `json:"mute_audio"`
+	MuteVideo   *bool      `json:"mute_video"`
+	MuteData    *bool      `json:"mute_data"`
+	Streams     []vrStream `json:"streams"`
+	Subscribe   []vrStream `json:"subscribe"`
+	Unsubscribe []vrStream `json:"unsubscribe"`
+}
+
+// vrStream janus 1.x 多流订阅请求中的流
+type vrStream struct {
+	Feed   int64  `json:"feed"`
+	Mid    string `json:"mid"`
+	SubMid string `json:"sub_mid"`
+}
+
+// notice 需要发给其他 handle 的事件
+type notice struct {
+	handleID int64
+	data     interface{}
+}
+
+// HandleMessage implements Plugin
+func (vr *VideoRoom) HandleMessage(s *Server, msg *Message) (reply Reply) {
+	var body vrBody
+	var notices []notice
+	if err := msg.Decode(&body); err != nil {
+		return Reply{Sync: true, Data: PluginError("videoroom", 422, err.Error())}
+	}
+	vr.lock.Lock()
+	switch body.Request {
+	case "create", "edit", "destroy", "exists", "list", "listparticipants", "allowed", "kick", "moderate":
+		reply, notices = vr.sync(&body)
+	default:
+		reply, notices = vr.async(msg, &body)
+	}
+	vr.lock.Unlock()
+	for _, n := range notices {
+		s.NotifyPlugin(n.handleID, n.data, nil)
+	}
+	return
+}
+
+func (vr *VideoRoom) sync(body *vrBody) (reply Reply, notices []notice) {
+	reply.Sync = true
+	room := vr.rooms[body.Room]
+	if room == nil && body.Request != "create" && body.Request != "exists" && body.Request != "list" {
+		reply.Data = PluginError("videoroom", VideoRoomErrNoSuchRoom, fmt.Sprintf("No such room (%d)", body.Room))
+		return
+	}
+	if room != nil && room.Secret != "" && body.Secret != room.Secret {
+		switch body.Request {
+		case "edit", "destroy", "allowed", "kick", "moderate":
+			reply.Data = PluginError("videoroom", VideoRoomErrUnauthorized, "Unauthorized (wrong secret)")
+			return
+		}
+	}
+	switch body.Request {
+	case "create":
+		if room != nil {
+			reply.Data = PluginError("videoroom", VideoRoomErrRoomExists, fmt.Sprintf("Room %d already exists", body.Room))
+			return
+		}
+		if body.Room == 0 {
+			vr.nextID++
+			body.Room = vr.nextID
+		}
+		vr.rooms[body.Room] = &Room{
+			ID:           body.Room,
+			Description:  body.Description,
+			Secret:       body.Secret,
+			Pin:          body.Pin,
+			IsPrivate:    body.IsPrivate,
+			Allowed:      body.Allowed,
+			participants: make(map[int64]*participant),
+		}
+		reply.Data = map[string]interface{}{"videoroom": "created", "room": body.Room, "permanent": false}
+	case "edit":
+		if body.NewDesc != "" {
+			room.Description = body.NewDesc
+		}
+		if body.NewSecret != "" {
+			room.Secret = body.NewSecret
+		}
+		if body.NewPin != "" {
+			room.Pin = body.NewPin
+		}
+		reply.Data = map[string]interface{}{"videoroom": "edited", "room": body.Room, "permanent": false}
+	case "destroy":
+		for _, p := range room.participants {
+			notices = append(notices, notice{p.handleID, map[string]interface{}{"videoroom": "destroyed", "room": room.ID}})
+		}
+		delete(vr.rooms, room.ID)
+		reply.Data = map[string]interface{}{"videoroom": "destroyed", "room": body.Room, "permanent": false}
+	case "exists":
+		reply.Data = map[string]interface{}{"videoroom": "success", "room": body.Room, "exists": room != nil}
+	case "list":
+		var list []map[string]interface{}
+		for _, r := range vr.sortedRooms() {
+			if r.IsPrivate {
+				continue
+			}
+			list = append(list, map[string]interface{}{
+				"room":             r.ID,
+				"description":      r.Description,
+				"pin_required":     r.Pin != "",
+				"num_participants": len(r.participants),
+			})
+		}
+		reply.Data = map[string]interface{}{"videoroom": "success", "list": list}
+	case "listparticipants":
+		var list []map[string]interface{}
+		for _, p := range room.sorted() {
+			list = append(list, map[string]interface{}{"id": p.id, "display": p.display, "publisher": p.published, "talking": p.talking})
+		}
+		reply.Data = map[string]interface{}{"videoroom": "participants", "room": room.ID, "participants": list}
+	case "allowed":
+		switch body.Action {
+		case "enable", "disable":
+		case "add":
+			room.Allowed = append(room.Allowed, body.Allowed...)
+		case "remove":
+			var kept []string
+			for _, a := range room.Allowed {
+				if !contains(body.Allowed, a) {
+					kept = append(kept, a)
+				}
+			}
+			room.Allowed = kept
+		default:
+			reply.Data = PluginError("videoroom", 430, fmt.Sprintf("Unsupported action '%s'", body.Action))
+			return
+		}
+		reply.Data = map[string]interface{}{"videoroom": "success", "room": room.ID, "allowed": room.Allowed}
+	case "kick":
+		p := room.participants[body.ID]
+		if p == nil {
+			reply.Data = PluginError("videoroom", VideoRoomErrNoSuchFeed, fmt.Sprintf("No such user %d in room %d", body.ID, room.ID))
+			return
+		}
+		delete(room.participants, p.id)
+		notices = append(notices, notice{p.handleID, map[string]interface{}{"videoroom": "event", "room": room.ID, "leaving": "ok", "reason": "kicked"}})
+		for _, other := range room.participants {
+			notices = append(notices, notice{other.handleID, map[string]interface{}{"videoroom": "event", "room": room.ID, "kicked": p.id}})
+		}
+		reply.Data = map[string]interface{}{"videoroom": "success"}
+	case "moderate":
+		if room.participants[body.ID] == nil {
+			reply.Data = PluginError("videoroom", VideoRoomErrNoSuchFeed, fmt.Sprintf("No such user %d in room %d", body.ID, room.ID))
+			return
+		}
+		for i, mute := range []*bool{body.MuteAudio, body.MuteVideo, body.MuteData} {
+			if kind := []string{"audio", "video", "data"}[i]; mute != nil {
+				state := "unmuted"
+				if *mute {
+					state = "muted"
+				}
+				notices = append(notices, room.broadcast(0, map[string]interface{}{"videoroom": "event", "room": room.ID, "id": body.ID, kind + "-moderation": state})...)
+			}
+		}
+		reply.Data = map[string]interface{}{"videoroom": "success"}
+	}
+	return
+}
+
+func (vr *VideoRoom) async(msg *Message, body *vrBody) (reply Reply, notices []notice) {
+	var roomID, _ = msg.State["room"].(int64)
+	var myID, _ = msg.State["id"].(int64)
+	var ptype, _ = msg.State["ptype"].(string)
+	if body.Request == "join" {
+		if ptype != "" {
+			reply.Data = PluginError("videoroom", VideoRoomErrAlreadyJoined, "Already in as a "+ptype)
+			return
+		}
+		room := vr.rooms[body.Room]
+		if room == nil {
+			reply.Data = PluginError("videoroom", VideoRoomErrNoSuchRoom, fmt.Sprintf("No such room (%d)", body.Room))
+			return
+		}
+		if room.Pin != "" && body.Pin != room.Pin {
+			reply.Data = PluginError("videoroom", VideoRoomErrUnauthorized, "Unauthorized (wrong pin)")
+			return
+		}
+		switch body.Ptype {
+		case "publisher":
+			return vr.joinPublisher(msg, room, body)
+		case "subscriber", "listener":
+			if body.Streams != nil {
+				return vr.joinMultiSubscriber(msg, room, body)
+			}
+			feed := room.participants[body.Feed]
+			if feed == nil || !feed.published {
+				reply.Data = PluginError("videoroom", VideoRoomErrNoSuchFeed, fmt.Sprintf("No such feed (%d)", body.Feed))
+				return
+			}
+			msg.State["room"] = room.ID
+			msg.State["ptype"] = "subscriber"
+			msg.State["feed"] = feed.id
+			reply.Data = map[string]interface{}{"videoroom": "attached", "room": room.ID, "id": feed.id, "display": feed.display}
+			reply.Jsep = jsepOf("offer")
+			return
+		}
+		reply.Data = PluginError("videoroom", VideoRoomErrInvalidRequest, fmt.Sprintf("Invalid element (ptype %s)", body.Ptype))
+		return
+	}
+	room := vr.rooms[roomID]
+	if ptype == "" || room == nil {
+		reply.Data = PluginError("videoroom", VideoRoomErrJoinFirst, "Can't handle requests before joining")
+		return
+	}
+	if ptype == "subscriber" {
+		switch body.Request {
+		case "subscribe", "unsubscribe", "update":
+			return vr.updateSubscription(msg, room, body)
+		case "start":
+			reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "started": "ok"}
+		case "pause":
+			reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "paused": "ok"}
+		case "configure":
+			reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "configured": "ok"}
+		case "switch":
+			feed := room.participants[body.Feed]
+			if feed == nil || !feed.published {
+				reply.Data = PluginError("videoroom", VideoRoomErrNoSuchFeed, fmt.Sprintf("No such feed (%d)", body.Feed))
+				return
+			}
+			msg.State["feed"] = feed.id
+			reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "switched": "ok", "id": feed.id}
+		case "leave":
+			delete(msg.State, "ptype")
+			reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "left": "ok"}
+		default:
+			reply.Data = PluginError("videoroom", VideoRoomErrInvalidRequest, fmt.Sprintf("Unknown request '%s'", body.Request))
+		}
+		return
+	}
+	me := room.participants[myID]
+	if me == nil {
+		reply.Data = PluginError("videoroom", VideoRoomErrJoinFirst, "Can't handle requests before joining")
+		return
+	}
+	switch body.Request {
+	case "publish", "configure":
+		wasPublished := me.published
+		if msg.HasJsep() {
+			me.published = true
+			me.audioCodec, me.videoCodec = "opus", "vp8"
+			reply.Jsep = jsepOf("answer")
+		}
+		if body.Display != nil {
+			me.display = *body.Display
+		}
+		reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "configured": "ok"}
+		if me.published && !wasPublished {
+			pub := []interface{}{me.publisherInfo()}
+			for _, other := range room.participants {
+				if other.id != me.id {
+					notices = append(notices, notice{other.handleID, map[string]interface{}{"videoroom": "event", "room": room.ID, "publishers": pub}})
+				}
+			}
+		}
+	case "unpublish":
+		if !me.published {
+			reply.Data = PluginError("videoroom", VideoRoomErrNotPublished, "Can't unpublish, not published")
+			return
+		}
+		me.published = false
+		reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "unpublished": "ok"}
+		notices = room.broadcast(me.id, map[string]interface{}{"videoroom": "event", "room": room.ID, "unpublished": me.id})
+	case "leave":
+		notices = vr.leave(room, me)
+		delete(msg.State, "ptype")
+		reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "leaving": "ok"}
+	case "relay_data":
+		reply.Data = map[string]interface{}{"videoroom": "event", "room": room.ID, "relay_data": "ok"}
+	default:
+		reply.Data = PluginError("videoroom", VideoRoomErrInvalidRequest, fmt.Sprintf("Unknown request '%s'", body.Request))
+	}
+	return
+}
+
+func (vr *VideoRoom) joinPublisher(msg *Message, room *Room, body *vrBody) (reply Reply, notices []notice) {
+	var pubs = []interface{}{}
+	id := body.ID
+	if id == 0 {
+		vr.nextID++
+		id = vr.nextID
+	} else if room.participants[id] != nil {
+		reply.Data = PluginError("videoroom", 436, fmt.Sprintf("User ID %d already exists", id))
+		return
+	}
+	p := &participant{id: id, handleID: msg.HandleID}
+	if body.Display != nil {
+		p.display = *body.Display
+	}
+	for _, other := range room.sorted() {
+		if other.published {
+			pubs = append(pubs, other.publisherInfo())
+		}
+	}
+	room.participants[id] = p
+	msg.State["room"] = room.ID
+	msg.State["id"] = id
+	msg.State["ptype"] = "publisher"
+	reply.Data = map[string]interface{}{
+		"videoroom":   "joined",
+		"room":        room.ID,
+		"description": room.Description,
+		"id":          id,
+		"private_id":  id + 1000000,
+		"publishers":  pubs,
+	}
+	return
+}
+
+// joinMultiSubscriber janus 1.x 以多流订阅者加入，订阅的流按订阅顺序分配 mid
+func (vr *VideoRoom) joinMultiSubscriber(msg *Message, room *Room, body *vrBody) (reply Reply, notices []notice) {
+	var streams []map[string]interface{}
+	var err map[string]interface{}
+	if streams, err = subscribeStreams(room, nil, body.Streams); err != nil {
+		reply.Data = err
+		return
+	}
+	msg.State["room"] = room.ID
+	msg.State["ptype"] = "subscriber"
+	msg.State["streams"] = streams
+	reply.Data = map[string]interface{}{"videoroom": "attached", "room": room.ID, "streams": streams}
+	reply.Jsep = jsepOf("offer")
+	return
+}
+
+// updateSubscription 处理 subscribe,unsubscribe,update，取消订阅的流标记为 inactive，mid 不再复用
+func (vr *VideoRoom) updateSubscription(msg *Message, room *Room, body *vrBody) (reply Reply, notices []notice) {
+	var streams, _ = msg.State["streams"].([]map[string]interface{})
+	var add, remove = body.Subscribe, body.Unsubscribe
+	var err map[string]interface{}
+	switch body.Request {
+	case "subscribe":
+		add = body.Streams
+	case "unsubscribe":
+		remove = body.Streams
+	}
+	for _, r := range remove {
+		for _, s := range streams {
+			if s["active"] == true && (r.SubMid == s["mid"] || (r.SubMid == "" && r.Feed == s["feed_id"] && (r.Mid == "" || r.Mid == s["feed_mid"]))) {
+				s["active"] = false
+				delete(s, "feed_id")
+				delete(s, "feed_mid")
+				delete(s, "feed_display")
+			}
+		}
+	}
+	if streams, err = subscribeStreams(room, streams, add); err != nil {
+		reply.Data = err
+		return
+	}
+	msg.State["streams"] = streams
+	reply.Data = map[string]interface{}{"videoroom": "updated", "room": room.ID, "streams": streams}
+	reply.Jsep = jsepOf("offer")
+	return
+}
+
+// subscribeStreams 把发布者的流加到订阅流列表，fake 发布者的 mid 0 为音频，mid 1 为视频
+func subscribeStreams(room *Room, streams []map[string]interface{}, add []vrStream) (out []map[string]interface{}, err map[string]interface{}) {
+	out = streams
+	for _, item := range add {
+		feed := room.participants[item.Feed]
+		if feed == nil || !feed.published {
+			return nil, PluginError("videoroom", VideoRoomErrNoSuchFeed, fmt.Sprintf("No such feed (%d)", item.Feed))
+		}
+		for _, fs := range []struct{ typ, mid, codec string }{{"audio", "0", feed.audioCodec}, {"video", "1", feed.videoCodec}} {
+			if fs.codec == "" || (item.Mid != "" && item.Mid != fs.mid) {
+				continue
+			}
+			out = append(out, map[string]interface{}{
+				"mindex": len(out), "mid": fmt.Sprint(len(out)), "type": fs.typ, "active": true, "codec": fs.codec,
+				"feed_id": feed.id, "feed_mid": fs.mid, "feed_display": feed.display,
+			})
+		}
+	}
+	return
+}
+
+// leave 参与者离开，通知其他人
+func (vr *VideoRoom) leave(room *Room, me *participant) (notices []notice) {
+	if me.published {
+		notices = append(notices, room.broadcast(me.id, map[string]interface{}{"videoroom": "event", "room": room.ID, "unpublished": me.id})...)
+	}
+	delete(room.participants, me.id)
+	notices = append(notices, room.broadcast(me.id, map[string]interface{}{"videoroom": "event", "room": room.ID, "leaving": me.id})...)
+	return
+}
+
+// Detach implements Detacher，发布者 handle 解绑时视为离开会议室
+func (vr *VideoRoom) Detach(s *Server, handleID int64, state map[string]interface{}) {
+	var notices []notice
+	roomID, _ := state["room"].(int64)
+	id, _ := state["id"].(int64)
+	vr.lock.Lock()
+	if room := vr.rooms[roomID]; room != nil {
+		if me := room.participants[id]; me != nil && me.handleID == handleID {
+			notices = vr.leave(room, me)
+		}
+	}
+	vr.lock.Unlock()
+	for _, n := range notices {
+		s.NotifyPlugin(n.handleID, n.data, nil)
+	}
+}
+
+// SetTalking 模拟参与者说话状态变化，通知会议室中的所有人
+func (vr *VideoRoom) SetTalking(s *Server, roomID, id int64, talking bool) {
+	var notices []notice
+	vr.lock.Lock()
+	if room := vr.rooms[roomID]; room != nil {
+		if p := room.participants[id]; p != nil {
+			p.talking = talking
+			ev := "stopped-talking"
+			if talking {
+				ev = "talking"
+			}
+			notices = room.broadcast(0, map[string]interface{}{"videoroom": ev, "room": roomID, "id": id, "audio-level-dBov-avg": -30.0})
+		}
+	}
+	vr.lock.Unlock()
+	for _, n := range notices {
+		s.NotifyPlugin(n.handleID, n.data, nil)
+	}
+}
+
+func (vr *VideoRoom) sortedRooms() (rooms []*Room) {
+	for _, r := range vr.rooms {
+		rooms = append(rooms, r)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return
+}
+
+func (room *Room) sorted() (list []*participant) {
+	for _, p := range room.participants {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+	return
+}
+
+// broadcast 发给除 except 外的所有参与者
+func (room *Room) broadcast(except int64, data interface{}) (notices []notice) {
+	for _, p := range room.sorted() {
+		if p.id != except {
+			notices = append(notices, notice{p.handleID, data})
+		}
+	}
+	return
+}
+
+func (p *participant) publisherInfo() map[string]interface{} {
+	return map[string]interface{}{"id": p.id, "display": p.display, "audio_codec": p.audioCodec, "video_codec": p.videoCodec}
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package webrtc
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// keepalive 默认配置，janus 默认 session_timeout 为 60 秒
+const (
+	DefaultKeepaliveInterval = 30 * time.Second
+	DefaultKeepaliveMisses   = 2
+)
+
+// Clock 时钟接口，测试时可替换以控制 keepalive 节奏
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SetKeepalive 设置 keepalive 间隔和连续多少次没有响应后断开重连，0 表示使用默认值
+func (cli *Client) SetKeepalive(interval time.Duration, misses int) *Client {
+	if interval <= 0 {
+		interval = DefaultKeepaliveInterval
+	}
+	if misses <= 0 {
+		misses = DefaultKeepaliveMisses
+	}
+	cli.keepaliveInterval = interval
+	cli.keepaliveMisses = misses
+	return cli
+}
+
+// SetClock 设置 keepalive 使用的时钟，nil 时使用系统时钟
+func (cli *Client) SetClock(c Clock) *Client {
+	if c == nil {
+		c = realClock{}
+	}
+	cli.clock = c
+	return cli
+}
+
+// MissedKeepalives 连续没有响应的 keepalive 次数
+func (js *Janus) MissedKeepalives() int {
+	return int(atomic.LoadInt32(&js.missed))
+}
+
+// keepaliveLoop 定时发送 keepalive，连接断开时释放会话
+func (js *Janus) keepaliveLoop() {
+	defer js.wg.Done()
+	for {
+		select {
+		case <-js.disconn:
+			js.Destroy()
+			js.log(LevelInfo, "session keepalive finish")
+			js.emit(SessionFinish)
+			return
+		case <-js.cli.clock.After(js.cli.keepaliveInterval):
+			if js.GetSessionID() > 0 && js.State() == SessionStateActive {
+				js.keepAlive()
+			}
+		}
+	}
+}
+
+// keepAlive 发送 keepalive，连续没有响应时关闭连接触发重连，会话已不存在时标记会话丢失
+func (js *Janus) keepAlive() {
+	var req janusRequest
+	var resp *JanusResponse
+	var err error
+	var tr = js.transport()
+	if tr == nil {
+		return
+	}
+	req.Janus = "keepalive"
+	req.SessionID = js.GetSessionID()
+	// 等待时间不超过 keepalive 间隔，下一次 keepalive 之前结束
+	var timeout = DefaultRequestTimeout
+	if js.cli.keepaliveInterval < timeout {
+		timeout = js.cli.keepaliveInterval
+	}
+	if resp, err = js.requestWait(&req, timeout); err == nil {
+		err = resp.HasError("keepalive")
+	}
+	if err == nil {
+		atomic.StoreInt32(&js.missed, 0)
+		return
+	}
+	js.cli.metrics.KeepaliveFail()
+	if isSessionGone(err) {
+		return
+	}
+	missed := atomic.AddInt32(&js.missed, 1)
+	js.log(LevelWarn, "keepalive fail", F("missed", missed), F(FieldError, err))
+	if errors.Is(err, ErrTimeout) && int(missed) >= js.cli.keepaliveMisses {
+		// 连接可能已失效，关闭后由读协程重连
+		atomic.StoreInt32(&js.missed, 0)
+		tr.Close()
+	}
+}
+
+// isSessionGone 服务器上的会话已不存在
+func isSessionGone(err error) bool {
+	return errors.Is(err, ErrSessionNotFound)
+}
+
+// markLost 标记会话丢失并通知使用方，只通知一次
+func (js *Janus) markLost() {
+	js.lostOnce.Do(func() {
+		js.setState(SessionStateClosed)
+		js.emit(SessionLost)
+	})
+}
+
+// sessionGone 服务器上的会话已超时或不存在，标记丢失并断开连接
+func (js *Janus) sessionGone(reason string) {
+	if js.State() == SessionStateClosed {
+		return
+	}
+	js.log(LevelWarn, "session gone", F("reason", reason))
+	js.markLost()
+	js.Destroy()
+}
@@ -0,0 +1,133 @@
+package webrtc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client/webrtc/janustest"
+)
+
+// fakeClock 由测试触发 keepalive，Now 返回真实时间
+type fakeClock struct {
+	tick chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{tick: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time                       { return time.Now() }
+func (c *fakeClock) After(time.Duration) <-chan time.Time { return c.tick }
+
+// fire 触发一次 keepalive，keepalive 协程取走后返回
+func (c *fakeClock) fire(t *testing.T) {
+	t.Helper()
+	select {
+	case c.tick <- time.Now():
+	case <-time.After(2 * time.Second):
+		t.Fatal("keepalive loop not waiting")
+	}
+}
+
+// newKeepaliveJanus 使用 fakeClock 的会话，keepalive 等待响应 50ms
+func newKeepaliveJanus(t *testing.T, srv *janustest.Server, misses int) (js *Janus, clock *fakeClock, events chan SessionEvent) {
+	t.Helper()
+	clock = newFakeClock()
+	events = make(chan SessionEvent, 8)
+	cli := NewClient(srv.URL(), "", WithLogger(NopLogger())).
+		SetKeepalive(50*time.Millisecond, misses).
+		SetClock(clock).
+		SetReconnect(ReconnectPolicy{Attempts: 3, MinBackoff: 10 * time.Millisecond})
+	js, err := cli.NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	js.SetEventCallBack(func(_ *Janus, ev SessionEvent) { events <- ev })
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		js.Close(ctx)
+		cancel()
+	})
+	return
+}
+
+func waitMissed(t *testing.T, js *Janus, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for js.MissedKeepalives() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("missed keepalives %d, want %d", js.MissedKeepalives(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitSessionEvent(t *testing.T, events chan SessionEvent, want SessionEvent) {
+	t.Helper()
+	for {
+		select {
+		case ev := <-events:
+			if ev == want {
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
+
+func TestKeepaliveAckResetsMisses(t *testing.T) {
+	srv := newTestServer(t)
+	js, clock, _ := newKeepaliveJanus(t, srv, 3)
+	srv.DropNext("keepalive")
+	clock.fire(t)
+	waitMissed(t, js, 1)
+	clock.fire(t)
+	waitMissed(t, js, 0)
+	if n := srv.CountRequests("keepalive"); n != 2 {
+		t.Fatalf("keepalive requests %d, want 2", n)
+	}
+}
+
+func TestKeepaliveMissesTriggerReconnect(t *testing.T) {
+	srv := newTestServer(t)
+	js, clock, events := newKeepaliveJanus(t, srv, 2)
+	srv.DropNext("keepalive")
+	srv.DropNext("keepalive")
+	clock.fire(t)
+	waitMissed(t, js, 1)
+	if n := srv.CountRequests("claim"); n != 0 {
+		t.Fatalf("reconnected after one missed keepalive")
+	}
+	// 达到上限后关闭连接，由读协程重连并 claim 原会话
+	clock.fire(t)
+	waitSessionEvent(t, events, SessionReconnecting)
+	waitSessionEvent(t, events, SessionReconnected)
+	if n := srv.CountRequests("claim"); n != 1 {
+		t.Fatalf("claim requests %d, want 1", n)
+	}
+	if n := js.MissedKeepalives(); n != 0 {
+		t.Fatalf("missed keepalives %d after reconnect, want 0", n)
+	}
+	if s := js.State(); s != SessionStateActive {
+		t.Fatalf("state %s, want %s", s, SessionStateActive)
+	}
+}
+
+func TestKeepaliveSessionGone(t *testing.T) {
+	srv := newTestServer(t)
+	js, clock, events := newKeepaliveJanus(t, srv, 2)
+	srv.FailNext("keepalive", janustest.ErrSessionNotFound, "No such session")
+	clock.fire(t)
+	waitSessionEvent(t, events, SessionLost)
+	if s := js.State(); s != SessionStateClosed {
+		t.Fatalf("state %s, want %s", s, SessionStateClosed)
+	}
+	if n := js.MissedKeepalives(); n != 0 {
+		t.Fatalf("missed keepalives %d, session gone is not a miss", n)
+	}
+	if n := srv.CountRequests("claim"); n != 0 {
+		t.Fatalf("claim requests %d, lost session must not reconnect", n)
+	}
+}
@@ -0,0 +1,223 @@
+package webrtc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/finove/golibused/pkg/logger"
+)
+
+// LogLevel 日志级别，数值与 log/slog 一致
+type LogLevel int
+
+// log level defined
+const (
+	LevelTrace LogLevel = -8 // 协议跟踪，输出完整消息，不隐藏 apisecret 和 SDP
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+	switch {
+	case l <= LevelTrace:
+		return "TRACE"
+	case l <= LevelDebug:
+		return "DEBUG"
+	case l <= LevelInfo:
+		return "INFO"
+	case l <= LevelWarn:
+		return "WARN"
+	}
+	return "ERROR"
+}
+
+// 日志字段名
+const (
+	FieldSession     = "session"
+	FieldHandle      = "handle"
+	FieldTag         = "tag"
+	FieldPlugin      = "plugin"
+	FieldTransaction = "transaction"
+	FieldEvent       = "event"
+	FieldLatency     = "latency"
+	FieldError       = "error"
+)
+
+// Field 结构化日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F 创建日志字段
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger 客户端日志接口，通过 Client.SetLogger 或 WithLogger 设置
+type Logger interface {
+	Enabled(level LogLevel) bool
+	Log(level LogLevel, msg string, fields ...Field)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Enabled(LogLevel) bool          { return false }
+func (nopLogger) Log(LogLevel, string, ...Field) {}
+
+// NopLogger 不输出任何日志
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+// libLogger 输出到 golibused logger，字段以 key=value 形式附加在消息后
+type libLogger struct {
+	level LogLevel
+}
+
+// DefaultLogger 输出到 golibused logger，低于 level 的日志不输出，客户端默认使用 DefaultLogger(LevelInfo)
+func DefaultLogger(level LogLevel) Logger {
+	return libLogger{level: level}
+}
+
+func (ll libLogger) Enabled(level LogLevel) bool {
+	return level >= ll.level
+}
+
+func (ll libLogger) Log(level LogLevel, msg string, fields ...Field) {
+	if !ll.Enabled(level) {
+		return
+	}
+	var out strings.Builder
+	out.WriteString(msg)
+	for _, f := range fields {
+		out.WriteString(fmt.Sprintf(" %s=%v", f.Key, f.Value))
+	}
+	switch {
+	case level >= LevelError:
+		logger.Error("%s", out.String())
+	case level >= LevelWarn:
+		logger.Warning("%s", out.String())
+	case level >= LevelInfo:
+		logger.Info("%s", out.String())
+	default:
+		logger.Debug("%s", out.String())
+	}
+}
+
+// SetLogger 设置日志输出，nil 时不输出日志
+func (cli *Client) SetLogger(l Logger) *Client {
+	if l == nil {
+		l = NopLogger()
+	}
+	cli.logger = l
+	return cli
+}
+
+// log 会话日志，附加 session 字段
+func (js *Janus) log(level LogLevel, msg string, fields ...Field) {
+	var l = js.cli.logger
+	if !l.Enabled(level) {
+		return
+	}
+	l.Log(level, msg, append([]Field{F(FieldSession, js.GetSessionID())}, fields...)...)
+}
+
+// log handle 日志，附加 session,handle,tag,plugin 字段
+func (h *Handle) log(level LogLevel, msg string, fields ...Field) {
+	var l = h.js.cli.logger
+	if !l.Enabled(level) {
+		return
+	}
+	l.Log(level, msg, append([]Field{
+		F(FieldSession, h.js.GetSessionID()),
+		F(FieldHandle, h.GetID()),
+		F(FieldTag, h.GetTag()),
+		F(FieldPlugin, h.plugin),
+	}, fields...)...)
+}
+
+// logRequest 记录发送的请求，keepalive 只在 debug 级别输出
+func (js *Janus) logRequest(req *janusRequest) {
+	var level = LevelInfo
+	if req.Janus == "keepalive" {
+		level = LevelDebug
+	}
+	if !js.cli.logger.Enabled(level) {
+		return
+	}
+	fields := []Field{F(FieldTransaction, req.Transaction), F(FieldEvent, req.Janus)}
+	if req.HandleID != 0 {
+		fields = append(fields, F(FieldHandle, req.HandleID))
+	}
+	if req.Plugin != "" {
+		fields = append(fields, F(FieldPlugin, req.Plugin))
+	}
+	fields = append(fields, F("request", req.format(js.tracing())))
+	js.log(level, "janus request", fields...)
+}
+
+// tracing 是否开启协议跟踪，开启时日志中输出完整消息
+func (js *Janus) tracing() bool {
+	return js.cli.logger.Enabled(LevelTrace)
+}
+
+// payload 日志中输出的消息内容，未开启协议跟踪时隐藏密码和 SDP
+func (js *Janus) payload(data []byte) string {
+	if js.tracing() {
+		return string(data)
+	}
+	return string(redactJSON(data))
+}
+
+// latency 请求耗时字段
+func latency(start time.Time) Field {
+	return F(FieldLatency, time.Since(start))
+}
+
+// redactKeys 未开启协议跟踪时隐藏的字段
+var redactKeys = map[string]bool{
+	"apisecret":    true,
+	"admin_secret": true,
+	"token":        true,
+	"secret":       true,
+	"ha1_secret":   true,
+	"pin":          true,
+	"sdp":          true,
+}
+
+// redactJSON 隐藏 json 消息中的密码和 SDP，不是 json 时只输出长度
+func redactJSON(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(fmt.Sprintf("<%d bytes>", len(data)))
+	}
+	out, _ := json.Marshal(redactValue(v))
+	return out
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if s, ok := item.(string); ok && redactKeys[k] && s != "" {
+				if k == "sdp" {
+					val[k] = fmt.Sprintf("<sdp %d bytes>", len(s))
+				} else {
+					val[k] = "***"
+				}
+				continue
+			}
+			val[k] = redactValue(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactValue(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,34 @@
+//go:build go1.21
+
+package webrtc
+
+import (
+	"context"
+	"log/slog"
+)
+
+// slogLogger 输出到 log/slog
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// SlogLogger 使用 log/slog 输出日志，LevelTrace 对应 slog.Level(-8)
+// 需要 Go 1.21 及以上，低于 1.21 的工具链编译时没有这个函数
+func SlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l}
+}
+
+func (sl slogLogger) Enabled(level LogLevel) bool {
+	return sl.l.Enabled(context.Background(), slog.Level(level))
+}
+
+func (sl slogLogger) Log(level LogLevel, msg string, fields ...Field) {
+	var attrs = make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		attrs = append(attrs, slog.Any(f.Key, f.Value))
+	}
+	sl.l.LogAttrs(context.Background(), slog.Level(level), msg, attrs...)
+}
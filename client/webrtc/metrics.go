@@ -0,0 +1,85 @@
+package webrtc
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 请求耗时阶段
+const (
+	PhaseAck   = "ack"   // 发送请求到收到第一个响应(ack/success/error)
+	PhaseEvent = "event" // 发送请求到收到异步事件
+)
+
+// Metrics 客户端指标回调，通过 Client.SetMetrics 或 WithMetrics 设置，实现需并发安全
+type Metrics interface {
+	Request(verb, request string)                     // 发送请求，request 为插件请求名，如 join,configure
+	Latency(verb, phase string, d time.Duration)      // 请求耗时，phase 为 PhaseAck 或 PhaseEvent
+	Timeout(verb string)                              // 请求超时
+	ErrorCode(verb string, code int)                  // janus 或插件返回错误码
+	KeepaliveFail()                                   // keepalive 失败
+	SessionOpened()                                   // 创建会话
+	SessionClosed()                                   // 会话释放
+	HandleAttached(handleID int64, plugin string)     // 绑定 handle
+	HandleDetached(handleID int64, plugin string)     // handle 解绑或随会话释放
+	HandleEvent(handleID int64, plugin, event string) // handle 事件，如 slowlink,media,hangup
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Request(string, string)                {}
+func (nopMetrics) Latency(string, string, time.Duration) {}
+func (nopMetrics) Timeout(string)                        {}
+func (nopMetrics) ErrorCode(string, int)                 {}
+func (nopMetrics) KeepaliveFail()                        {}
+func (nopMetrics) SessionOpened()                        {}
+func (nopMetrics) SessionClosed()                        {}
+func (nopMetrics) HandleAttached(int64, string)          {}
+func (nopMetrics) HandleDetached(int64, string)          {}
+func (nopMetrics) HandleEvent(int64, string, string)     {}
+
+// SetMetrics 设置指标回调，nil 时不统计
+func (cli *Client) SetMetrics(m Metrics) *Client {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	cli.metrics = m
+	return cli
+}
+
+// observeRequest 统计发送的请求，message 请求按插件请求名统计
+func (js *Janus) observeRequest(req *janusRequest, msg []byte) {
+	var request string
+	if req.Janus == "message" {
+		var body struct {
+			Body struct {
+				Request string `json:"request"`
+			} `json:"body"`
+		}
+		json.Unmarshal(msg, &body)
+		request = body.Body.Request
+	}
+	js.cli.metrics.Request(req.Janus, request)
+}
+
+// observeResult 统计请求结果，超时和错误码
+func (js *Janus) observeResult(verb string, resp *JanusResponse, err error) {
+	var je Error
+	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			js.cli.metrics.Timeout(verb)
+		}
+		return
+	}
+	if err = resp.HasError(); err != nil && errors.As(err, &je) {
+		js.cli.metrics.ErrorCode(verb, je.Code())
+	}
+}
+
+// removeHandle 从会话中删除 handle，只统计一次
+func (js *Janus) removeHandle(h *Handle) {
+	if _, ok := js.handles.LoadAndDelete(h.GetID()); ok {
+		js.cli.metrics.HandleDetached(h.GetID(), h.plugin)
+	}
+}
@@ -0,0 +1,264 @@
+package webrtc
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 请求耗时直方图默认分桶，单位秒
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type verbRequest struct {
+	verb, request string
+}
+
+type verbPhase struct {
+	verb, phase string
+}
+
+type verbCode struct {
+	verb string
+	code int
+}
+
+type handleEvent struct {
+	handleID int64
+	plugin   string
+	event    string
+}
+
+// MetricsRegistry 内存中的指标统计，实现 Metrics，可按 Prometheus 文本格式导出
+type MetricsRegistry struct {
+	lock           sync.Mutex
+	buckets        []float64
+	requests       map[verbRequest]uint64
+	latency        map[verbPhase]*histogram
+	timeouts       map[string]uint64
+	errorCodes     map[verbCode]uint64
+	keepaliveFails uint64
+	sessions       int64
+	handles        map[string]int64
+	handleEvents   map[handleEvent]uint64
+}
+
+// NewMetricsRegistry 创建指标统计，buckets 为空时使用 DefaultLatencyBuckets
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &MetricsRegistry{
+		buckets:      buckets,
+		requests:     make(map[verbRequest]uint64),
+		latency:      make(map[verbPhase]*histogram),
+		timeouts:     make(map[string]uint64),
+		errorCodes:   make(map[verbCode]uint64),
+		handles:      make(map[string]int64),
+		handleEvents: make(map[handleEvent]uint64),
+	}
+}
+
+// Request implements Metrics
+func (mr *MetricsRegistry) Request(verb, request string) {
+	mr.lock.Lock()
+	mr.requests[verbRequest{verb, request}]++
+	mr.lock.Unlock()
+}
+
+// Latency implements Metrics
+func (mr *MetricsRegistry) Latency(verb, phase string, d time.Duration) {
+	var v = d.Seconds()
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+	hist := mr.latency[verbPhase{verb, phase}]
+	if hist == nil {
+		hist = &histogram{counts: make([]uint64, len(mr.buckets))}
+		mr.latency[verbPhase{verb, phase}] = hist
+	}
+	for i, le := range mr.buckets {
+		if v <= le {
+			hist.counts[i]++
+		}
+	}
+	hist.sum += v
+	hist.count++
+}
+
+// Timeout implements Metrics
+func (mr *MetricsRegistry) Timeout(verb string) {
+	mr.lock.Lock()
+	mr.timeouts[verb]++
+	mr.lock.Unlock()
+}
+
+// ErrorCode implements Metrics
+func (mr *MetricsRegistry) ErrorCode(verb string, code int) {
+	mr.lock.Lock()
+	mr.errorCodes[verbCode{verb, code}]++
+	mr.lock.Unlock()
+}
+
+// KeepaliveFail implements Metrics
+func (mr *MetricsRegistry) KeepaliveFail() {
+	mr.lock.Lock()
+	mr.keepaliveFails++
+	mr.lock.Unlock()
+}
+
+// SessionOpened implements Metrics
+func (mr *MetricsRegistry) SessionOpened() {
+	mr.lock.Lock()
+	mr.sessions++
+	mr.lock.Unlock()
+}
+
+// SessionClosed implements Metrics
+func (mr *MetricsRegistry) SessionClosed() {
+	mr.lock.Lock()
+	mr.sessions--
+	mr.lock.Unlock()
+}
+
+// HandleAttached implements Metrics
+func (mr *MetricsRegistry) HandleAttached(handleID int64, plugin string) {
+	mr.lock.Lock()
+	mr.handles[plugin]++
+	mr.lock.Unlock()
+}
+
+// HandleDetached implements Metrics，同时清除该 handle 的事件统计
+func (mr *MetricsRegistry) HandleDetached(handleID int64, plugin string) {
+	mr.lock.Lock()
+	mr.handles[plugin]--
+	for key := range mr.handleEvents {
+		if key.handleID == handleID {
+			delete(mr.handleEvents, key)
+		}
+	}
+	mr.lock.Unlock()
+}
+
+// HandleEvent implements Metrics
+func (mr *MetricsRegistry) HandleEvent(handleID int64, plugin, event string) {
+	mr.lock.Lock()
+	mr.handleEvents[handleEvent{handleID, plugin, event}]++
+	mr.lock.Unlock()
+}
+
+// WriteTo 按 Prometheus 文本格式输出所有指标
+func (mr *MetricsRegistry) WriteTo(w io.Writer) (n int64, err error) {
+	var out strings.Builder
+	var lines []string
+	mr.lock.Lock()
+
+	writeHeader(&out, "janus_requests_total", "counter", "Janus requests sent by verb and plugin request.")
+	lines = lines[:0]
+	for k, v := range mr.requests {
+		lines = append(lines, fmt.Sprintf("janus_requests_total{verb=%q,request=%q} %d\n", k.verb, k.request, v))
+	}
+	writeSorted(&out, lines)
+
+	writeHeader(&out, "janus_request_duration_seconds", "histogram", "Janus request latency until ack or event.")
+	var keys []verbPhase
+	for k := range mr.latency {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].verb != keys[j].verb {
+			return keys[i].verb < keys[j].verb
+		}
+		return keys[i].phase < keys[j].phase
+	})
+	for _, k := range keys {
+		hist := mr.latency[k]
+		labels := fmt.Sprintf("verb=%q,phase=%q", k.verb, k.phase)
+		for i, le := range mr.buckets {
+			out.WriteString(fmt.Sprintf("janus_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), hist.counts[i]))
+		}
+		out.WriteString(fmt.Sprintf("janus_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, hist.count))
+		out.WriteString(fmt.Sprintf("janus_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(hist.sum, 'g', -1, 64)))
+		out.WriteString(fmt.Sprintf("janus_request_duration_seconds_count{%s} %d\n", labels, hist.count))
+	}
+
+	writeHeader(&out, "janus_request_timeouts_total", "counter", "Janus requests that timed out.")
+	lines = lines[:0]
+	for k, v := range mr.timeouts {
+		lines = append(lines, fmt.Sprintf("janus_request_timeouts_total{verb=%q} %d\n", k, v))
+	}
+	writeSorted(&out, lines)
+
+	writeHeader(&out, "janus_errors_total", "counter", "Janus and plugin error codes.")
+	lines = lines[:0]
+	for k, v := range mr.errorCodes {
+		lines = append(lines, fmt.Sprintf("janus_errors_total{verb=%q,code=\"%d\"} %d\n", k.verb, k.code, v))
+	}
+	writeSorted(&out, lines)
+
+	writeHeader(&out, "janus_keepalive_failures_total", "counter", "Failed keepalive requests.")
+	out.WriteString(fmt.Sprintf("janus_keepalive_failures_total %d\n", mr.keepaliveFails))
+
+	writeHeader(&out, "janus_sessions", "gauge", "Live Janus sessions.")
+	out.WriteString(fmt.Sprintf("janus_sessions %d\n", mr.sessions))
+
+	writeHeader(&out, "janus_handles", "gauge", "Live Janus handles by plugin.")
+	lines = lines[:0]
+	for k, v := range mr.handles {
+		lines = append(lines, fmt.Sprintf("janus_handles{plugin=%q} %d\n", k, v))
+	}
+	writeSorted(&out, lines)
+
+	writeHeader(&out, "janus_handle_events_total", "counter", "Janus handle events such as slowlink, media and hangup.")
+	lines = lines[:0]
+	for k, v := range mr.handleEvents {
+		lines = append(lines, fmt.Sprintf("janus_handle_events_total{handle=\"%d\",plugin=%q,event=%q} %d\n", k.handleID, k.plugin, k.event, v))
+	}
+	writeSorted(&out, lines)
+
+	mr.lock.Unlock()
+	m, err := io.WriteString(w, out.String())
+	return int64(m), err
+}
+
+func writeHeader(out *strings.Builder, name, kind, help string) {
+	out.WriteString(fmt.Sprintf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind))
+}
+
+func writeSorted(out *strings.Builder, lines []string) {
+	sort.Strings(lines)
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// ServeHTTP 输出 Prometheus 文本格式指标
+func (mr *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mr.WriteTo(w)
+}
+
+// Serve 在 addr 上启动 HTTP 服务，/metrics 输出指标，如 127.0.0.1:9090，返回的 http.Server 用于关闭
+func (mr *MetricsRegistry) Serve(addr string) (srv *http.Server, err error) {
+	var ln net.Listener
+	if ln, err = net.Listen("tcp", addr); err != nil {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", mr)
+	srv = &http.Server{Addr: ln.Addr().String(), Handler: mux}
+	go srv.Serve(ln)
+	return
+}
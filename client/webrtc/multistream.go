@@ -0,0 +1,276 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/finove/webrtctest/client"
+	pion "github.com/pion/webrtc/v3"
+)
+
+// StreamSink 处理多流订阅收到的远端轨道，stream 为轨道所在 mid 对应的发布者流，在单独的协程中调用
+type StreamSink func(stream VideoRoomSubStream, track *pion.TrackRemote, receiver *pion.RTPReceiver)
+
+// MultiSubscriber janus 1.x videoroom 多流订阅，一个订阅 handle 和一个 Unified Plan PeerConnection 接收多个发布者的流
+// 订阅变化后用 janus 发来的 offer 重新协商，发布者离开等 janus 主动发送的 updated 事件也会自动重新协商
+type MultiSubscriber struct {
+	js        *Janus
+	room      int64
+	pin       string
+	privateID int64
+	config    pion.Configuration
+	sink      StreamSink
+	onError   func(err error)
+	h         *Handle
+	pc        *pion.PeerConnection
+	nego      sync.Mutex // 请求和协商串行进行
+	lock      sync.Mutex
+	streams   []VideoRoomSubStream
+	done      chan struct{}
+}
+
+// NewMultiSubscriber 创建多流订阅者，第一次 Subscribe 时加入会议室
+func NewMultiSubscriber(js *Janus, room int64, sink StreamSink) *MultiSubscriber {
+	return &MultiSubscriber{js: js, room: room, sink: sink}
+}
+
+// SetPin 设置加入会议室的密码
+func (ms *MultiSubscriber) SetPin(pin string) *MultiSubscriber {
+	ms.pin = pin
+	return ms
+}
+
+// SetPrivateID 设置发布者的 private_id，会议室配置 require_pvtid 时需要
+func (ms *MultiSubscriber) SetPrivateID(id int64) *MultiSubscriber {
+	ms.privateID = id
+	return ms
+}
+
+// SetConfiguration 设置创建 PeerConnection 使用的配置，SDPSemantics 固定为 Unified Plan
+func (ms *MultiSubscriber) SetConfiguration(config pion.Configuration) *MultiSubscriber {
+	ms.config = config
+	return ms
+}
+
+// OnError 设置自动重新协商失败的回调
+func (ms *MultiSubscriber) OnError(f func(err error)) *MultiSubscriber {
+	ms.onError = f
+	return ms
+}
+
+// Handle 返回订阅 handle，加入之前为 nil
+func (ms *MultiSubscriber) Handle() *Handle {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return ms.h
+}
+
+// PeerConnection 返回订阅使用的 PeerConnection，加入之前为 nil
+func (ms *MultiSubscriber) PeerConnection() *pion.PeerConnection {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return ms.pc
+}
+
+// Streams 返回当前订阅的流，按 mindex 排列
+func (ms *MultiSubscriber) Streams() []VideoRoomSubStream {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	return append([]VideoRoomSubStream(nil), ms.streams...)
+}
+
+// Stream 按订阅方的 mid 查找对应的发布者流
+func (ms *MultiSubscriber) Stream(mid string) (stream VideoRoomSubStream, ok bool) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+	for _, s := range ms.streams {
+		if s.Mid == mid {
+			return s, true
+		}
+	}
+	return
+}
+
+// Subscribe 订阅发布者的流，第一次调用时加入会议室，协商完成后返回
+func (ms *MultiSubscriber) Subscribe(ctx context.Context, streams ...VideoRoomSubscribeStream) (err error) {
+	ms.nego.Lock()
+	defer ms.nego.Unlock()
+	if ms.Handle() == nil {
+		return ms.join(ctx, streams)
+	}
+	return ms.request(ctx, &VideoRoomSubscription{Request: "subscribe", Streams: streams})
+}
+
+// Unsubscribe 取消订阅发布者的流，协商完成后返回
+func (ms *MultiSubscriber) Unsubscribe(ctx context.Context, streams ...VideoRoomSubscribeStream) (err error) {
+	ms.nego.Lock()
+	defer ms.nego.Unlock()
+	return ms.request(ctx, &VideoRoomSubscription{Request: "unsubscribe", Streams: streams})
+}
+
+// Update 同时订阅和取消订阅，只需要一次重新协商
+func (ms *MultiSubscriber) Update(ctx context.Context, subscribe, unsubscribe []VideoRoomSubscribeStream) (err error) {
+	ms.nego.Lock()
+	defer ms.nego.Unlock()
+	return ms.request(ctx, &VideoRoomSubscription{Request: "update", Subscribe: subscribe, Unsubscribe: unsubscribe})
+}
+
+// Close 关闭 PeerConnection 并解绑订阅 handle
+func (ms *MultiSubscriber) Close(ctx context.Context) (err error) {
+	ms.nego.Lock()
+	ms.lock.Lock()
+	h, pc, done := ms.h, ms.pc, ms.done
+	ms.h, ms.pc, ms.streams = nil, nil, nil
+	ms.lock.Unlock()
+	ms.nego.Unlock()
+	if pc != nil {
+		pc.Close()
+	}
+	if h == nil {
+		return
+	}
+	err = h.shutdown(ctx)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = contextError(ctx, "multistream close", "")
+		}
+	}
+	return
+}
+
+// join 创建 PeerConnection 并以多流订阅者加入
+func (ms *MultiSubscriber) join(ctx context.Context, streams []VideoRoomSubscribeStream) (err error) {
+	var h *Handle
+	var pc *pion.PeerConnection
+	var join VideoRoomJoin
+	var resp *JanusResponse
+	var roomResp VideoRoomResponse
+	var config = ms.config
+	config.SDPSemantics = pion.SDPSemanticsUnifiedPlan
+	if h, err = ms.js.AttachContext(ctx, PluginVideoRoom, fmt.Sprintf("multisubscriber-%d", ms.room)); err != nil {
+		return
+	}
+	if pc, err = pion.NewPeerConnection(config); err != nil {
+		h.DetachContext(ctx)
+		return
+	}
+	pc.OnTrack(ms.onTrack)
+	join.AsMultiSubscriber(ms.room, streams...)
+	join.Pin = ms.pin
+	join.PrivateID = ms.privateID
+	if resp, err = h.SendContext(ctx, &join, nil, &roomResp); err == nil {
+		ms.lock.Lock()
+		ms.h, ms.pc = h, pc
+		ms.lock.Unlock()
+		err = ms.negotiate(ctx, &resp.Jsep, &roomResp)
+	}
+	if err != nil {
+		ms.lock.Lock()
+		ms.h, ms.pc, ms.streams = nil, nil, nil
+		ms.lock.Unlock()
+		pc.Close()
+		h.DetachContext(ctx)
+		return fmt.Errorf("multistream join room %d fail:%w", ms.room, err)
+	}
+	done := make(chan struct{})
+	ms.lock.Lock()
+	ms.done = done
+	ms.lock.Unlock()
+	go ms.eventLoop(h, done)
+	return
+}
+
+// request 发送修改订阅的请求，janus 返回 offer 时重新协商
+func (ms *MultiSubscriber) request(ctx context.Context, req *VideoRoomSubscription) (err error) {
+	var resp *JanusResponse
+	var roomResp VideoRoomResponse
+	var h = ms.Handle()
+	if h == nil {
+		return fmt.Errorf("multistream %s fail:%w", req.Request, ErrHandleNotFound)
+	}
+	if resp, err = h.SendContext(ctx, req, nil, &roomResp); err == nil {
+		err = ms.negotiate(ctx, &resp.Jsep, &roomResp)
+	}
+	if err != nil {
+		err = fmt.Errorf("multistream %s fail:%w", req.Request, err)
+	}
+	return
+}
+
+// negotiate 更新 mid 对应关系，有 offer 时回复 answer，需持有 nego
+func (ms *MultiSubscriber) negotiate(ctx context.Context, jsep *Jsep, resp *VideoRoomResponse) (err error) {
+	var answer pion.SessionDescription
+	var pc = ms.PeerConnection()
+	if resp.VideoRoom == "attached" || resp.VideoRoom == "updated" {
+		ms.lock.Lock()
+		ms.streams = resp.Streams
+		ms.lock.Unlock()
+	}
+	if jsep == nil || jsep.Type != "offer" || pc == nil {
+		return
+	}
+	if err = pc.SetRemoteDescription(pion.SessionDescription{Type: pion.SDPTypeOffer, SDP: jsep.SDP}); err != nil {
+		return
+	}
+	if answer, err = pc.CreateAnswer(nil); err != nil {
+		return
+	}
+	gathered := pion.GatheringCompletePromise(pc)
+	if err = pc.SetLocalDescription(answer); err != nil {
+		return
+	}
+	select {
+	case <-gathered:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	start := VideoRoomCommon{Request: "start"}
+	_, err = ms.Handle().SendContext(ctx, &start, &Jsep{Type: "answer", SDP: pc.LocalDescription().SDP, Trickle: client.Bool(false)})
+	return
+}
+
+// eventLoop 处理 janus 主动发送的 updated 事件，请求的响应由请求方处理
+func (ms *MultiSubscriber) eventLoop(h *Handle, done chan struct{}) {
+	defer close(done)
+	for ev := range h.Events() {
+		msg, ok := ev.(*PluginMessage)
+		if !ok || msg.Transaction != "" || msg.VideoRoom() == nil || msg.VideoRoom().VideoRoom != "updated" {
+			continue
+		}
+		ms.nego.Lock()
+		if ms.Handle() == h {
+			ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+			if err := ms.negotiate(ctx, msg.Jsep, msg.VideoRoom()); err != nil {
+				ms.js.log(LevelWarn, "multistream renegotiate fail", F("room", ms.room), F(FieldError, err))
+				if ms.onError != nil {
+					ms.onError(err)
+				}
+			}
+			cancel()
+		}
+		ms.nego.Unlock()
+	}
+}
+
+// onTrack 按 transceiver 的 mid 找到对应的发布者流
+func (ms *MultiSubscriber) onTrack(track *pion.TrackRemote, receiver *pion.RTPReceiver) {
+	var stream VideoRoomSubStream
+	if pc := ms.PeerConnection(); pc != nil {
+		for _, t := range pc.GetTransceivers() {
+			if t.Receiver() == receiver {
+				stream.Mid = t.Mid()
+				break
+			}
+		}
+	}
+	if s, ok := ms.Stream(stream.Mid); ok {
+		stream = s
+	}
+	ms.js.log(LevelInfo, "multistream track", F("mid", stream.Mid), F("feed", stream.FeedID), F("codec", track.Codec().MimeType))
+	if ms.sink != nil {
+		go ms.sink(stream, track, receiver)
+	}
+}
@@ -0,0 +1,167 @@
+package webrtc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ClientOption 客户端配置选项
+type ClientOption func(*Client)
+
+// WithTLSConfig 使用指定的 TLS 配置，其他 TLS 选项在此基础上修改
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(cli *Client) {
+		if cfg != nil {
+			cli.tlsConfig = cfg.Clone()
+		}
+	}
+}
+
+// WithRootCAs 使用自定义 CA 证书池校验服务器证书
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(cli *Client) {
+		cli.tls().RootCAs = pool
+	}
+}
+
+// WithClientCertificate 双向 TLS 认证时使用的客户端证书
+func WithClientCertificate(certs ...tls.Certificate) ClientOption {
+	return func(cli *Client) {
+		cli.tls().Certificates = append(cli.tls().Certificates, certs...)
+	}
+}
+
+// WithServerName 校验证书时使用的服务器名
+func WithServerName(name string) ClientOption {
+	return func(cli *Client) {
+		cli.tls().ServerName = name
+	}
+}
+
+// WithInsecureSkipVerify 不校验服务器证书，只用于测试环境
+func WithInsecureSkipVerify() ClientOption {
+	return func(cli *Client) {
+		cli.tls().InsecureSkipVerify = true
+	}
+}
+
+// WithProxy 通过 HTTP 代理连接服务器，默认使用环境变量中的代理配置
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) ClientOption {
+	return func(cli *Client) {
+		cli.proxy = proxy
+	}
+}
+
+// WithProxyURL 通过指定地址的 HTTP 代理连接服务器
+func WithProxyURL(u *url.URL) ClientOption {
+	return WithProxy(http.ProxyURL(u))
+}
+
+// WithHeader 握手和 HTTP 请求时附加的请求头
+func WithHeader(key, value string) ClientOption {
+	return func(cli *Client) {
+		cli.header.Add(key, value)
+	}
+}
+
+// WithHandshakeTimeout 连接握手超时时间
+func WithHandshakeTimeout(d time.Duration) ClientOption {
+	return func(cli *Client) {
+		cli.handshakeTimeout = d
+	}
+}
+
+// WithReadLimit 单条消息最大字节数，超过时断开连接
+func WithReadLimit(n int64) ClientOption {
+	return func(cli *Client) {
+		cli.readLimit = n
+	}
+}
+
+// WithTransport 使用自定义传输，同 SetTransport
+func WithTransport(f TransportDialer) ClientOption {
+	return func(cli *Client) {
+		cli.SetTransport(f)
+	}
+}
+
+// WithReconnect 断线重连策略，同 SetReconnect
+func WithReconnect(p ReconnectPolicy) ClientOption {
+	return func(cli *Client) {
+		cli.SetReconnect(p)
+	}
+}
+
+// WithPluginCheck Attach 前检查插件是否加载，同 SetPluginCheck(true)
+func WithPluginCheck() ClientOption {
+	return func(cli *Client) {
+		cli.SetPluginCheck(true)
+	}
+}
+
+// WithLogger 日志输出，同 SetLogger
+func WithLogger(l Logger) ClientOption {
+	return func(cli *Client) {
+		cli.SetLogger(l)
+	}
+}
+
+// WithMetrics 指标回调，同 SetMetrics
+func WithMetrics(m Metrics) ClientOption {
+	return func(cli *Client) {
+		cli.SetMetrics(m)
+	}
+}
+
+// WithKeepalive keepalive 间隔和连续没有响应的次数，同 SetKeepalive
+func WithKeepalive(interval time.Duration, misses int) ClientOption {
+	return func(cli *Client) {
+		cli.SetKeepalive(interval, misses)
+	}
+}
+
+// WithClock keepalive 使用的时钟，同 SetClock
+func WithClock(c Clock) ClientOption {
+	return func(cli *Client) {
+		cli.SetClock(c)
+	}
+}
+
+// WithRecorder 录制会话收发的消息，同 SetRecorder
+func WithRecorder(rec *Recorder) ClientOption {
+	return func(cli *Client) {
+		cli.SetRecorder(rec)
+	}
+}
+
+func (cli *Client) tls() *tls.Config {
+	if cli.tlsConfig == nil {
+		cli.tlsConfig = &tls.Config{}
+	}
+	return cli.tlsConfig
+}
+
+// setupDialers 按配置创建客户端自己的 WebSocket dialer 和 HTTP client，不修改全局默认值
+func (cli *Client) setupDialers() {
+	var netDialer = &net.Dialer{Timeout: cli.handshakeTimeout}
+	cli.wsDialer = &websocket.Dialer{
+		Proxy:            cli.proxy,
+		NetDialContext:   netDialer.DialContext,
+		TLSClientConfig:  cli.tlsConfig,
+		HandshakeTimeout: cli.handshakeTimeout,
+	}
+	cli.httpClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:               cli.proxy,
+			DialContext:         netDialer.DialContext,
+			TLSClientConfig:     cli.tlsConfig,
+			TLSHandshakeTimeout: cli.handshakeTimeout,
+		},
+	}
+}
@@ -0,0 +1,383 @@
+package webrtc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PoolPolicy 连接池创建新会话时选择服务器的策略
+type PoolPolicy int
+
+// pool policy defined
+const (
+	PoolRoundRobin    PoolPolicy = iota // 轮询
+	PoolLeastSessions                   // 会话数最少的服务器
+	PoolPinnedRoom                      // 按房间号固定到服务器，同一房间总是在同一服务器
+)
+
+// DefaultHealthInterval 默认健康检查间隔
+const DefaultHealthInterval = 10 * time.Second
+
+// PoolMember 连接池中服务器的状态
+type PoolMember struct {
+	Server    string
+	Healthy   bool // 健康检查通过
+	Accepting bool // 服务器接受新会话
+	Sessions  int
+	LastCheck time.Time
+	LastError error
+	Info      *ServerInfo
+}
+
+type poolMember struct {
+	PoolMember
+	cli   *Client
+	fails int
+}
+
+// Pool 多个 janus 服务器的连接池，负责健康检查、选择服务器和故障转移
+type Pool struct {
+	lock       sync.Mutex
+	members    []*poolMember
+	policy     PoolPolicy
+	next       int
+	rooms      map[int64]*poolMember // 房间所在服务器
+	sessions   map[*Janus]*poolMember
+	interval   time.Duration
+	failLimit  int
+	onFailover func(old, new *Janus, err error)
+	quit       chan struct{}
+	stop       sync.Once
+}
+
+// NewPool 创建连接池，每个服务器使用相同的 secret 和配置，服务器初始视为可用
+func NewPool(servers []string, secret string, opts ...ClientOption) (p *Pool) {
+	p = &Pool{
+		rooms:     make(map[int64]*poolMember),
+		sessions:  make(map[*Janus]*poolMember),
+		interval:  DefaultHealthInterval,
+		failLimit: 2,
+		quit:      make(chan struct{}),
+	}
+	for _, server := range servers {
+		m := &poolMember{cli: NewClient(server, secret, opts...)}
+		m.Server = server
+		m.Healthy = true
+		m.Accepting = true
+		p.members = append(p.members, m)
+	}
+	return
+}
+
+// SetPolicy 设置选择服务器的策略
+func (p *Pool) SetPolicy(policy PoolPolicy) *Pool {
+	p.lock.Lock()
+	p.policy = policy
+	p.lock.Unlock()
+	return p
+}
+
+// SetHealthCheck 设置健康检查间隔和连续失败多少次后判定服务器不可用，需在 Start 之前设置
+func (p *Pool) SetHealthCheck(interval time.Duration, failLimit int) *Pool {
+	if interval > 0 {
+		p.interval = interval
+	}
+	if failLimit > 0 {
+		p.failLimit = failLimit
+	}
+	return p
+}
+
+// OnFailover 设置故障转移回调，old 为原会话，new 为新服务器上的会话，转移失败时 new 为 nil
+func (p *Pool) OnFailover(f func(old, new *Janus, err error)) *Pool {
+	p.lock.Lock()
+	p.onFailover = f
+	p.lock.Unlock()
+	return p
+}
+
+// Start 启动定时健康检查
+func (p *Pool) Start() *Pool {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+				p.Check(ctx)
+				cancel()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// Close 停止健康检查，不释放已创建的会话
+func (p *Pool) Close() {
+	p.stop.Do(func() {
+		close(p.quit)
+	})
+}
+
+// Check 立即用 info 请求检查所有服务器，服务器判定不可用时转移其上的会话
+func (p *Pool) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *poolMember) {
+			defer wg.Done()
+			info, err := m.cli.InfoContext(ctx)
+			p.report(m, info, err)
+		}(m)
+	}
+	wg.Wait()
+}
+
+// report 记录健康检查结果，连续失败达到上限时转移会话
+func (p *Pool) report(m *poolMember, info *ServerInfo, err error) {
+	var lost []*Janus
+	p.lock.Lock()
+	m.LastCheck = time.Now()
+	m.LastError = err
+	if err == nil {
+		m.Info = info
+		m.fails = 0
+		m.Healthy = true
+		m.Accepting = info.AcceptingNewSessions
+	} else if m.fails++; m.fails >= p.failLimit && m.Healthy {
+		m.Healthy = false
+		for js, owner := range p.sessions {
+			if owner == m {
+				lost = append(lost, js)
+			}
+		}
+	}
+	p.lock.Unlock()
+	for _, js := range lost {
+		go p.failover(js)
+	}
+}
+
+// Members 返回所有服务器的状态
+func (p *Pool) Members() (members []PoolMember) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, m := range p.members {
+		members = append(members, m.PoolMember)
+	}
+	return
+}
+
+// NewJanus 按策略选择服务器创建会话
+func (p *Pool) NewJanus(ctx context.Context) (js *Janus, err error) {
+	return p.newJanus(ctx, 0, nil, nil)
+}
+
+// NewJanusForRoom 在房间所在服务器上创建会话，房间还没有服务器时按策略选择并记录
+func (p *Pool) NewJanusForRoom(ctx context.Context, room int64) (js *Janus, err error) {
+	return p.newJanus(ctx, room, nil, nil)
+}
+
+// newJanus 选择服务器创建会话，exclude 为不选择的服务器，setup 在会话的内部协程启动之前调用
+func (p *Pool) newJanus(ctx context.Context, room int64, exclude map[*poolMember]bool, setup func(js *Janus)) (js *Janus, err error) {
+	var m *poolMember
+	var tried = make(map[*poolMember]bool)
+	for item := range exclude {
+		tried[item] = true
+	}
+	var init = func(js *Janus) {
+		js.pool = p
+		if setup != nil {
+			setup(js)
+		}
+	}
+	for {
+		p.lock.Lock()
+		m = p.pick(room, tried)
+		p.lock.Unlock()
+		if m == nil {
+			err = ErrNoHealthyServer
+			return
+		}
+		if js, err = m.cli.newJanusContext(ctx, init); err == nil {
+			break
+		}
+		p.report(m, nil, err)
+		if ctx.Err() != nil {
+			return
+		}
+		// 创建失败的服务器本次不再选择
+		tried[m] = true
+	}
+	p.lock.Lock()
+	p.sessions[js] = m
+	m.Sessions++
+	if room != 0 {
+		p.rooms[room] = m
+	}
+	p.lock.Unlock()
+	return
+}
+
+// pick 选择服务器，需持有锁
+func (p *Pool) pick(room int64, exclude map[*poolMember]bool) (m *poolMember) {
+	var healthy []*poolMember
+	for _, item := range p.members {
+		if item.Healthy && item.Accepting && !exclude[item] {
+			healthy = append(healthy, item)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if room != 0 {
+		if m = p.rooms[room]; m != nil && m.Healthy && m.Accepting && !exclude[m] {
+			return m
+		}
+		if p.policy == PoolPinnedRoom {
+			idx := room % int64(len(healthy))
+			if idx < 0 {
+				idx = -idx
+			}
+			return healthy[idx]
+		}
+	}
+	switch p.policy {
+	case PoolLeastSessions, PoolPinnedRoom:
+		m = healthy[0]
+		for _, item := range healthy[1:] {
+			if item.Sessions < m.Sessions {
+				m = item
+			}
+		}
+	default:
+		m = healthy[p.next%len(healthy)]
+		p.next++
+	}
+	return
+}
+
+// PinRoom 记录房间所在服务器
+func (p *Pool) PinRoom(room int64, server string) *Pool {
+	p.lock.Lock()
+	for _, m := range p.members {
+		if m.Server == server {
+			p.rooms[room] = m
+		}
+	}
+	p.lock.Unlock()
+	return p
+}
+
+// RoomServer 查询房间所在服务器，服务器不可用时 ok 为 false
+func (p *Pool) RoomServer(room int64) (server string, ok bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if m := p.rooms[room]; m != nil && m.Healthy {
+		return m.Server, true
+	}
+	return
+}
+
+// Server 返回会话所在的服务器
+func (p *Pool) Server(js *Janus) (server string, ok bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if m := p.sessions[js]; m != nil {
+		return m.Server, true
+	}
+	return
+}
+
+// noteRoom 会话加入房间时记录房间所在服务器
+func (p *Pool) noteRoom(js *Janus, room int64) {
+	p.lock.Lock()
+	if m := p.sessions[js]; m != nil && room != 0 {
+		if _, ok := p.rooms[room]; !ok {
+			p.rooms[room] = m
+		}
+	}
+	p.lock.Unlock()
+}
+
+// sessionEvent 会话丢失时转移，会话结束时不再跟踪
+func (p *Pool) sessionEvent(js *Janus, ev SessionEvent) {
+	switch ev {
+	case SessionLost:
+		go p.failover(js)
+	case SessionFinish:
+		p.lock.Lock()
+		if m := p.sessions[js]; m != nil {
+			m.Sessions--
+			delete(p.sessions, js)
+		}
+		p.lock.Unlock()
+	}
+}
+
+// failover 创建新会话代替丢失的会话，原服务器不可用时选择其他服务器，handle 需由使用方在新会话上重新 attach
+// 同一会话只转移一次，结束后原会话不再由连接池跟踪
+func (p *Pool) failover(old *Janus) {
+	old.trLock.Lock()
+	started := old.failover
+	old.failover = true
+	old.trLock.Unlock()
+	if started {
+		return
+	}
+	var exclude = make(map[*poolMember]bool)
+	p.lock.Lock()
+	from := p.sessions[old]
+	if from != nil && !from.Healthy {
+		// 服务器不可用，房间随服务器丢失，之后按策略重新选择
+		exclude[from] = true
+		for room, m := range p.rooms {
+			if m == from {
+				delete(p.rooms, room)
+			}
+		}
+	}
+	f := p.onFailover
+	p.lock.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	old.trLock.RLock()
+	callback, size, policy := old.callback, old.eventBuffer, old.eventPolicy
+	old.trLock.RUnlock()
+	// 回调和事件通道配置在新会话的协程启动之前设置
+	js, err := p.newJanus(ctx, 0, exclude, func(js *Janus) {
+		js.callback = callback
+		js.eventBuffer = size
+		js.eventPolicy = policy
+	})
+	if err == nil {
+		old.trLock.Lock()
+		old.movedTo = js
+		old.trLock.Unlock()
+		old.log(LevelWarn, "session moved", F("server", js.GetServer()), F("new_session", js.GetSessionID()))
+	} else {
+		old.log(LevelError, "session failover fail", F(FieldError, err))
+	}
+	old.Destroy()
+	p.sessionEvent(old, SessionFinish)
+	old.emit(SessionMoved)
+	if f != nil {
+		f(old, js, err)
+	}
+}
+
+// Pool 返回创建会话的连接池，不是通过连接池创建时为 nil
+func (js *Janus) Pool() *Pool {
+	return js.pool
+}
+
+// MovedTo 故障转移后代替该会话的新会话，没有转移时为 nil
+func (js *Janus) MovedTo() *Janus {
+	js.trLock.RLock()
+	defer js.trLock.RUnlock()
+	return js.movedTo
+}
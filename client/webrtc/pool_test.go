@@ -0,0 +1,128 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolFailoverKeepsSessionConfig(t *testing.T) {
+	srv1, srv2 := newTestServer(t), newTestServer(t)
+	p := NewPool([]string{srv1.URL(), srv2.URL()}, "", WithLogger(NopLogger())).SetHealthCheck(time.Second, 1)
+	moved := make(chan *Janus, 1)
+	p.OnFailover(func(old, new *Janus, err error) {
+		if err != nil {
+			t.Error(err)
+		}
+		moved <- new
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	js, err := p.NewJanus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer js.Close(ctx)
+	if js.Pool() != p {
+		t.Fatal("session not bound to pool")
+	}
+	var callback = func(*Janus, SessionEvent) {}
+	js.SetEventCallBack(callback).SetEventBuffer(7, DropOldest)
+	if server, _ := p.Server(js); server != srv1.URL() {
+		t.Fatalf("session on %s, want %s", server, srv1.URL())
+	}
+	srv1.Close()
+	p.Check(ctx)
+	var next *Janus
+	select {
+	case next = <-moved:
+	case <-ctx.Done():
+		t.Fatal("no failover")
+	}
+	defer next.Close(ctx)
+	if js.MovedTo() != next || next.Pool() != p {
+		t.Fatal("moved session not linked")
+	}
+	if server, _ := p.Server(next); server != srv2.URL() {
+		t.Fatalf("moved to %s, want %s", server, srv2.URL())
+	}
+	if size, policy := next.eventConfig(); size != 7 || policy != DropOldest {
+		t.Fatalf("event config %d %v, want 7 DropOldest", size, policy)
+	}
+	next.trLock.RLock()
+	hasCallback := next.callback != nil
+	next.trLock.RUnlock()
+	if !hasCallback {
+		t.Fatal("callback not carried over")
+	}
+}
+
+func TestPoolNoHealthyServer(t *testing.T) {
+	srv := newTestServer(t)
+	p := NewPool([]string{srv.URL()}, "", WithLogger(NopLogger())).SetHealthCheck(time.Second, 1)
+	srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	p.Check(ctx)
+	if _, err := p.NewJanus(ctx); !errors.Is(err, ErrNoHealthyServer) {
+		t.Fatalf("got %v, want ErrNoHealthyServer", err)
+	}
+	if e, ok := ErrNoHealthyServer.(Error); !ok || e.Code() != ErrCodeTransport {
+		t.Fatalf("ErrNoHealthyServer %#v is not a transport Error", ErrNoHealthyServer)
+	}
+}
+
+func TestPoolRetryTriesEachMemberOnce(t *testing.T) {
+	down1, down2, up := newTestServer(t), newTestServer(t), newTestServer(t)
+	down1.Close()
+	down2.Close()
+	// 失败上限足够大，失败的服务器仍视为可用，只靠本次已尝试的集合排除
+	p := NewPool([]string{down1.URL(), down2.URL(), up.URL()}, "", WithLogger(NopLogger())).
+		SetPolicy(PoolLeastSessions).
+		SetHealthCheck(time.Second, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	js, err := p.NewJanus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer js.Close(ctx)
+	if server, _ := p.Server(js); server != up.URL() {
+		t.Fatalf("session on %s, want %s", server, up.URL())
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, m := range p.members[:2] {
+		if m.fails != 1 {
+			t.Fatalf("%s tried %d times, want 1", m.Server, m.fails)
+		}
+	}
+}
+
+func TestPoolFailoverOnce(t *testing.T) {
+	srv1, srv2 := newTestServer(t), newTestServer(t)
+	p := NewPool([]string{srv1.URL(), srv2.URL()}, "", WithLogger(NopLogger())).SetPolicy(PoolLeastSessions)
+	var calls int32
+	p.OnFailover(func(old, new *Janus, err error) {
+		atomic.AddInt32(&calls, 1)
+		if new != nil {
+			new.Destroy()
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	js, err := p.NewJanus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.failover(js)
+	p.failover(js)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("failover ran %d times, want 1", n)
+	}
+	if _, ok := p.Server(js); ok {
+		t.Fatal("moved session still tracked by the pool")
+	}
+}
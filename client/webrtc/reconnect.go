@@ -0,0 +1,195 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SessionEvent 会话事件类型
+type SessionEvent string
+
+// session event defined
+const (
+	SessionReconnecting SessionEvent = "reconnecting"   // 连接断开，开始重连
+	SessionReconnected  SessionEvent = "reconnected"    // 重连成功，会话已重新 claim
+	SessionLost         SessionEvent = "lost"           // 重连失败或服务器会话已不存在
+	SessionFinish       SessionEvent = "session finish" // 会话结束
+	SessionMoved        SessionEvent = "moved"          // 服务器不可用，连接池已在其他服务器上创建新会话，见 MovedTo
+)
+
+// ReconnectPolicy 断线重连策略，Attempts 为 0 时不重连
+type ReconnectPolicy struct {
+	Attempts   int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultReconnectPolicy 默认重连策略
+var DefaultReconnectPolicy = ReconnectPolicy{
+	Attempts:   10,
+	MinBackoff: time.Second,
+	MaxBackoff: 30 * time.Second,
+}
+
+// SetReconnect 设置断线重连策略
+func (cli *Client) SetReconnect(p ReconnectPolicy) *Client {
+	cli.reconnect = p
+	return cli
+}
+
+func (js *Janus) transport() (tr Transport) {
+	js.trLock.RLock()
+	tr = js.tr
+	js.trLock.RUnlock()
+	return
+}
+
+// swapTransport 当前传输仍为 expected 且会话未释放时替换为 tr
+func (js *Janus) swapTransport(expected, tr Transport) (ok bool) {
+	js.trLock.Lock()
+	if !js.closed && js.tr == expected {
+		js.tr = tr
+		ok = true
+	}
+	js.trLock.Unlock()
+	return
+}
+
+// emit 通知连接池和使用方，回调在单独的协程中按事件发生的顺序依次调用
+func (js *Janus) emit(ev SessionEvent) {
+	if js.pool != nil {
+		js.pool.sessionEvent(js, ev)
+	}
+	js.trLock.RLock()
+	callback := js.callback
+	js.trLock.RUnlock()
+	if callback == nil {
+		return
+	}
+	js.emitLock.Lock()
+	js.emitQueue = append(js.emitQueue, ev)
+	if js.emitting {
+		js.emitLock.Unlock()
+		return
+	}
+	js.emitting = true
+	js.emitLock.Unlock()
+	go js.deliverEvents()
+}
+
+// deliverEvents 依次把排队的会话事件交给回调，队列为空时退出
+func (js *Janus) deliverEvents() {
+	for {
+		js.emitLock.Lock()
+		if len(js.emitQueue) == 0 {
+			js.emitting = false
+			js.emitLock.Unlock()
+			return
+		}
+		ev := js.emitQueue[0]
+		js.emitQueue = js.emitQueue[1:]
+		js.emitLock.Unlock()
+		js.trLock.RLock()
+		callback := js.callback
+		js.trLock.RUnlock()
+		if callback != nil {
+			callback(js, ev)
+		}
+	}
+}
+
+// reconnectSession 按退避策略重新连接服务器并 claim 原会话，handles 和等待中的请求保持不变
+func (js *Janus) reconnectSession(old Transport) bool {
+	var policy = js.cli.reconnect
+	var backoff = policy.MinBackoff
+	if policy.Attempts <= 0 || js.GetSessionID() == 0 {
+		js.markLost()
+		return false
+	}
+	js.setState(SessionStateReconnecting)
+	js.emit(SessionReconnecting)
+	for i := 0; i < policy.Attempts; i++ {
+		if !js.sleep(backoff) {
+			// 等待期间会话已被释放
+			return false
+		}
+		if backoff *= 2; policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+		ctx, cancel := js.quitContext(DefaultRequestTimeout)
+		tr, err := js.cli.dialTransport(ctx)
+		cancel()
+		if err != nil {
+			js.log(LevelWarn, "session reconnect fail", F("attempt", i+1), F("attempts", policy.Attempts), F(FieldError, err))
+			continue
+		}
+		if !js.swapTransport(old, tr) {
+			// 重连期间会话已被释放
+			tr.Close()
+			return false
+		}
+		js.startReader(tr)
+		if err = js.claim(); err == nil {
+			js.log(LevelInfo, "session reconnected", F("server", js.GetServer()))
+			js.setState(SessionStateActive)
+			js.emit(SessionReconnected)
+			return true
+		}
+		js.log(LevelWarn, "session claim fail", F("attempt", i+1), F("attempts", policy.Attempts), F(FieldError, err))
+		if !js.swapTransport(tr, old) {
+			tr.Close()
+			return false
+		}
+		tr.Close()
+		if isSessionGone(err) {
+			// 服务器上的会话已超时释放，不再重试
+			break
+		}
+	}
+	js.markLost()
+	return false
+}
+
+// sleep 等待 d，会话被释放时提前返回 false
+func (js *Janus) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-js.quit:
+		return false
+	}
+}
+
+// quitContext 超时或会话被释放时取消的 context
+func (js *Janus) quitContext(timeout time.Duration) (ctx context.Context, cancel context.CancelFunc) {
+	ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	go func() {
+		select {
+		case <-js.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return
+}
+
+// claim 在新连接上接管已有会话
+func (js *Janus) claim() (err error) {
+	var req janusRequest
+	var resp *JanusResponse
+	req.Janus = "claim"
+	req.SessionID = js.GetSessionID()
+	if resp, err = js.requestWait(&req); err != nil {
+		return
+	}
+	if err = resp.HasError("claim"); err != nil {
+		return
+	}
+	if resp.Janus != "success" {
+		err = fmt.Errorf("unexpected claim response %s", resp.Janus)
+	}
+	return
+}
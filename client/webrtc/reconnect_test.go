@@ -0,0 +1,101 @@
+package webrtc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReconnectClaimsSession(t *testing.T) {
+	srv := newTestServer(t)
+	cli := NewClient(srv.URL(), "", WithLogger(NopLogger())).SetReconnect(ReconnectPolicy{Attempts: 3, MinBackoff: 10 * time.Millisecond})
+	js, err := cli.NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer js.Close(context.Background())
+	events := make(chan SessionEvent, 4)
+	js.SetEventCallBack(func(_ *Janus, ev SessionEvent) { events <- ev })
+	srv.DropConnections()
+	for _, want := range []SessionEvent{SessionReconnecting, SessionReconnected} {
+		select {
+		case ev := <-events:
+			if ev != want {
+				t.Fatalf("event %s, want %s", ev, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+	if n := srv.CountRequests("claim"); n != 1 {
+		t.Fatalf("claim requests %d, want 1", n)
+	}
+}
+
+func TestCloseInterruptsReconnectBackoff(t *testing.T) {
+	srv := newTestServer(t)
+	cli := NewClient(srv.URL(), "", WithLogger(NopLogger())).SetReconnect(ReconnectPolicy{Attempts: 3, MinBackoff: time.Minute})
+	js, err := cli.NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconnecting := make(chan struct{})
+	js.SetEventCallBack(func(_ *Janus, ev SessionEvent) {
+		if ev == SessionReconnecting {
+			close(reconnecting)
+		}
+	})
+	srv.DropConnections()
+	select {
+	case <-reconnecting:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reconnecting event")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	js.Close(ctx)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("close took %v while reconnect backoff pending", d)
+	}
+}
+
+func TestSessionEventsInOrder(t *testing.T) {
+	srv := newTestServer(t)
+	cli := NewClient(srv.URL(), "", WithLogger(NopLogger())).SetReconnect(ReconnectPolicy{Attempts: 3, MinBackoff: time.Millisecond})
+	js, err := cli.NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(chan SessionEvent, 8)
+	js.SetEventCallBack(func(_ *Janus, ev SessionEvent) {
+		// 慢回调，之后的事件需排队等待
+		if ev == SessionReconnecting {
+			time.Sleep(50 * time.Millisecond)
+		}
+		events <- ev
+	})
+	srv.DropConnections()
+	// 收到 claim 时会话处于重连中，之后等待重连完成
+	for srv.CountRequests("claim") == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = js.WaitState(ctx, SessionStateActive); err != nil {
+		t.Fatal(err)
+	}
+	if err = js.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []SessionEvent{SessionReconnecting, SessionReconnected, SessionFinish} {
+		select {
+		case ev := <-events:
+			if ev != want {
+				t.Fatalf("event %s, want %s", ev, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s event", want)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package webrtc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 录制消息方向
+const (
+	DirectionOut = "out" // 客户端发给服务器
+	DirectionIn  = "in"  // 服务器发给客户端
+)
+
+// TrafficRecord 录制文件中的一行
+type TrafficRecord struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"dir"`
+	SessionID int64           `json:"session_id,omitempty"`
+	HandleID  int64           `json:"handle_id,omitempty"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Recorder 把会话收发的所有消息按 JSONL 格式写入文件，
+// 默认与日志一样隐藏 apisecret、token、admin_secret 等密码和 SDP，SetRaw 开启后录制原始消息
+type Recorder struct {
+	lock   sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	err    error
+	raw    bool
+}
+
+// NewRecorder 录制到 w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// CreateRecorder 录制到文件，文件已存在时追加
+func CreateRecorder(path string) (rec *Recorder, err error) {
+	var f *os.File
+	if f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return
+	}
+	rec = NewRecorder(f)
+	rec.closer = f
+	return
+}
+
+// SetRaw 录制未隐藏的原始消息，包含密码和 SDP，回放需要 SDP 时使用，录制文件需妥善保管
+func (rec *Recorder) SetRaw(raw bool) *Recorder {
+	rec.lock.Lock()
+	rec.raw = raw
+	rec.lock.Unlock()
+	return rec
+}
+
+// Err 第一次写入失败的错误
+func (rec *Recorder) Err() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	return rec.err
+}
+
+// Close 关闭录制文件
+func (rec *Recorder) Close() (err error) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	if rec.closer != nil {
+		err = rec.closer.Close()
+		rec.closer = nil
+	}
+	return
+}
+
+func (rec *Recorder) record(dir string, sessionID, handleID int64, msg []byte) {
+	var item = TrafficRecord{Time: time.Now(), Direction: dir, SessionID: sessionID, HandleID: handleID}
+	if !json.Valid(msg) {
+		return
+	}
+	rec.lock.Lock()
+	if item.Message = msg; !rec.raw {
+		item.Message = redactJSON(msg)
+	}
+	if err := rec.enc.Encode(&item); err != nil && rec.err == nil {
+		rec.err = err
+	}
+	rec.lock.Unlock()
+}
+
+// SetRecorder 录制之后创建的会话收发的消息，nil 时不录制，admin 连接不录制
+func (cli *Client) SetRecorder(rec *Recorder) *Client {
+	cli.recorder = rec
+	return cli
+}
+
+// recordTransport 录制经过传输的消息
+type recordTransport struct {
+	Transport
+	rec *Recorder
+}
+
+func (rec *Recorder) wrap(tr Transport) Transport {
+	return &recordTransport{Transport: tr, rec: rec}
+}
+
+// Write 先记录再发送，保证录制中请求在响应之前
+func (rt *recordTransport) Write(sessionID, handleID int64, msg []byte) (err error) {
+	rt.rec.record(DirectionOut, sessionID, handleID, msg)
+	return rt.Transport.Write(sessionID, handleID, msg)
+}
+
+func (rt *recordTransport) Read() (msg []byte, err error) {
+	if msg, err = rt.Transport.Read(); err == nil {
+		rt.rec.record(DirectionIn, 0, 0, msg)
+	}
+	return
+}
+
+// ReadRecords 读取录制文件内容
+func ReadRecords(r io.Reader) (records []TrafficRecord, err error) {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var item TrafficRecord
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err = json.Unmarshal(scanner.Bytes(), &item); err != nil {
+			err = fmt.Errorf("record line %d: %w", line, err)
+			return
+		}
+		records = append(records, item)
+	}
+	err = scanner.Err()
+	return
+}
+
+// Replayer 回放录制的流量，通过 Client.SetTransport(rp.Dialer()) 代替真实服务器
+// 客户端每发送一个请求，对应录制中的下一个请求，之后到下一个请求前录制的服务器消息依次交给 consumeEvent，
+// 消息中的 transaction 替换为客户端实际使用的 transaction；客户端额外发送的 keepalive 直接回复 ack
+// 默认录制的 SDP 已隐藏，需要回放协商过程时录制使用 Recorder.SetRaw
+type Replayer struct {
+	lock    sync.Mutex
+	records []TrafficRecord
+	pos     int
+	txns    map[string]string // 录制的 transaction -> 实际 transaction
+	pending [][]byte
+	notify  chan struct{}
+	done    chan struct{}
+	doneOne sync.Once
+}
+
+// NewReplayer 从录制内容创建回放
+func NewReplayer(r io.Reader) (rp *Replayer, err error) {
+	var records []TrafficRecord
+	if records, err = ReadRecords(r); err != nil {
+		return
+	}
+	rp = &Replayer{
+		records: records,
+		txns:    make(map[string]string),
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	rp.lock.Lock()
+	rp.release()
+	rp.lock.Unlock()
+	return
+}
+
+// LoadReplay 从录制文件创建回放
+func LoadReplay(path string) (rp *Replayer, err error) {
+	var f *os.File
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	return NewReplayer(f)
+}
+
+// Dialer 回放使用的传输，同一时间只应有一个会话使用
+func (rp *Replayer) Dialer() TransportDialer {
+	return func(ctx context.Context, server string) (Transport, error) {
+		return &replayTransport{rp: rp, quit: make(chan struct{})}, nil
+	}
+}
+
+// Done 所有录制消息已回放，并且读协程已处理完最后一条消息时关闭
+func (rp *Replayer) Done() <-chan struct{} {
+	return rp.done
+}
+
+// Remaining 还未回放的录制条数
+func (rp *Replayer) Remaining() int {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	return len(rp.records) - rp.pos + len(rp.pending)
+}
+
+// release 放出到下一个请求前的服务器消息，需持有锁
+func (rp *Replayer) release() {
+	for rp.pos < len(rp.records) && rp.records[rp.pos].Direction != DirectionOut {
+		rp.pending = append(rp.pending, rp.rewrite(rp.records[rp.pos].Message))
+		rp.pos++
+	}
+	select {
+	case rp.notify <- struct{}{}:
+	default:
+	}
+}
+
+// rewrite 替换消息中的 transaction
+func (rp *Replayer) rewrite(msg []byte) []byte {
+	var fields map[string]interface{}
+	if json.Unmarshal(msg, &fields) != nil {
+		return msg
+	}
+	if txn, ok := fields["transaction"].(string); ok {
+		if actual, ok := rp.txns[txn]; ok {
+			fields["transaction"] = actual
+			if out, err := json.Marshal(fields); err == nil {
+				return out
+			}
+		}
+	}
+	return msg
+}
+
+// match 客户端发送请求，对应到录制中的下一个同类请求
+func (rp *Replayer) match(msg []byte) (err error) {
+	var req struct {
+		Janus       string `json:"janus"`
+		Transaction string `json:"transaction"`
+		SessionID   int64  `json:"session_id"`
+	}
+	if err = json.Unmarshal(msg, &req); err != nil {
+		return
+	}
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	for rp.pos < len(rp.records) {
+		var rec struct {
+			Janus       string `json:"janus"`
+			Transaction string `json:"transaction"`
+		}
+		json.Unmarshal(rp.records[rp.pos].Message, &rec)
+		if rec.Janus == req.Janus {
+			rp.txns[rec.Transaction] = req.Transaction
+			rp.pos++
+			rp.release()
+			return
+		}
+		if rec.Janus != "keepalive" {
+			break
+		}
+		// 录制时的 keepalive，回放时跳过
+		rp.pos++
+		rp.release()
+	}
+	if req.Janus == "keepalive" {
+		ack, _ := json.Marshal(map[string]interface{}{"janus": "ack", "session_id": req.SessionID, "transaction": req.Transaction})
+		rp.pending = append(rp.pending, ack)
+		rp.release()
+		return
+	}
+	return fmt.Errorf("replay mismatch: unexpected request %s at record %d", req.Janus, rp.pos+1)
+}
+
+// next 取出下一条服务器消息
+func (rp *Replayer) next() (msg []byte, ok bool) {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	if len(rp.pending) == 0 {
+		// 读协程再次读取时说明之前的消息已处理完
+		if rp.pos >= len(rp.records) {
+			rp.doneOne.Do(func() {
+				close(rp.done)
+			})
+		}
+		return
+	}
+	msg = rp.pending[0]
+	rp.pending = rp.pending[1:]
+	return msg, true
+}
+
+// replayTransport 回放传输
+type replayTransport struct {
+	rp   *Replayer
+	quit chan struct{}
+	once sync.Once
+}
+
+func (rt *replayTransport) Write(sessionID, handleID int64, msg []byte) error {
+	select {
+	case <-rt.quit:
+		return fmt.Errorf("replay transport closed")
+	default:
+	}
+	return rt.rp.match(msg)
+}
+
+func (rt *replayTransport) Read() (msg []byte, err error) {
+	for {
+		var ok bool
+		if msg, ok = rt.rp.next(); ok {
+			return
+		}
+		select {
+		case <-rt.rp.notify:
+		case <-rt.quit:
+			return nil, fmt.Errorf("replay transport closed")
+		}
+	}
+}
+
+func (rt *replayTransport) Close() error {
+	rt.once.Do(func() {
+		close(rt.quit)
+	})
+	return nil
+}
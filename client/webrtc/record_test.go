@@ -0,0 +1,130 @@
+package webrtc
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordSession 带 apisecret 创建会话并 attach，返回录制内容
+func recordSession(t *testing.T, rec *Recorder, buf *bytes.Buffer) string {
+	t.Helper()
+	srv := newTestServer(t)
+	srv.SetSecret("s3cret")
+	js, err := NewClient(srv.URL(), "s3cret", WithLogger(NopLogger()), WithRecorder(rec)).NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = js.Attach(PluginVideoRoom, "record"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = js.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadRecords(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Fatal("nothing recorded")
+	}
+	return buf.String()
+}
+
+func TestRecorderRedactsByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	out := recordSession(t, NewRecorder(&buf), &buf)
+	if strings.Contains(out, "s3cret") {
+		t.Fatalf("recording contains apisecret: %s", out)
+	}
+	if !strings.Contains(out, `"apisecret":"***"`) {
+		t.Fatalf("apisecret not redacted: %s", out)
+	}
+}
+
+func TestRecorderRaw(t *testing.T) {
+	var buf bytes.Buffer
+	out := recordSession(t, NewRecorder(&buf).SetRaw(true), &buf)
+	if !strings.Contains(out, `"apisecret":"s3cret"`) {
+		t.Fatalf("raw recording without apisecret: %s", out)
+	}
+}
+
+func TestRecordReplayRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	srv := newTestServer(t)
+	clock := newFakeClock()
+	js, err := NewClient(srv.URL(), "", WithLogger(NopLogger()), WithRecorder(NewRecorder(&buf)), WithClock(clock)).NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := js.Attach(PluginVideoRoom, "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 录制中有一次 keepalive
+	clock.fire(t)
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.CountRequests("keepalive") != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("keepalive not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var exists VideoRoomResponse
+	if _, err = h.Send(&VideoRoomCommon{Request: "exists", Room: 1234}, nil, &exists); err != nil || !exists.Exists {
+		t.Fatalf("record exists got %v %v", exists.Exists, err)
+	}
+	sessionID, handleID := js.GetSessionID(), h.GetID()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err = js.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rp, err := NewReplayer(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock = newFakeClock()
+	// keepalive 超时 1s，第二次 fire 返回时第一次 keepalive 已结束
+	js, err = NewClient("replay", "", WithLogger(NopLogger()), WithTransport(rp.Dialer()), WithClock(clock), WithKeepalive(time.Second, 1)).NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, err = js.Attach(PluginVideoRoom, "replay"); err != nil {
+		t.Fatal(err)
+	}
+	if js.GetSessionID() != sessionID || h.GetID() != handleID {
+		t.Fatalf("replay session %d handle %d, want %d %d", js.GetSessionID(), h.GetID(), sessionID, handleID)
+	}
+	// 录制的 keepalive 跳过，transaction 替换后响应对应到实际请求
+	exists = VideoRoomResponse{}
+	if _, err = h.Send(&VideoRoomCommon{Request: "exists", Room: 1234}, nil, &exists); err != nil || !exists.Exists {
+		t.Fatalf("replay exists got %v %v", exists.Exists, err)
+	}
+	// 录制中没有的 keepalive 由回放直接回复
+	clock.fire(t)
+	clock.fire(t)
+	if n := js.MissedKeepalives(); n != 0 {
+		t.Fatalf("missed %d keepalives in replay", n)
+	}
+	if err = js.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rp.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("replay not done, %d records remaining", rp.Remaining())
+	}
+	if n := rp.Remaining(); n != 0 {
+		t.Fatalf("%d records remaining", n)
+	}
+}
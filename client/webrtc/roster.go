@@ -0,0 +1,334 @@
+package webrtc
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// RosterChange 会议室参与者变化类型
+type RosterChange string
+
+// roster change defined
+const (
+	RosterJoined      RosterChange = "joined"      // 参与者加入
+	RosterPublished   RosterChange = "published"   // 参与者开始发布
+	RosterUpdated     RosterChange = "updated"     // 发布的流、说话或禁言状态变化
+	RosterUnpublished RosterChange = "unpublished" // 参与者停止发布
+	RosterLeft        RosterChange = "left"        // 参与者离开
+)
+
+// RosterStream 参与者发布的一路媒体流
+type RosterStream struct {
+	Type      string // audio,video,data
+	Mid       string // janus 0.x 没有 mid
+	Codec     string
+	Simulcast bool
+	Disabled  bool
+	Muted     bool // 被管理员禁止
+}
+
+// RosterParticipant 参与者状态
+type RosterParticipant struct {
+	ID        int64
+	Display   string
+	Published bool
+	Streams   []RosterStream
+	Talking   bool
+}
+
+// RosterEvent 参与者变化通知，Participant 为变化后的状态，离开时为离开前的状态
+type RosterEvent struct {
+	Change      RosterChange
+	Room        int64
+	Participant RosterParticipant
+}
+
+// RosterSnapshot 某一时刻的会议室状态，Participants 不包括自己，按 ID 排序
+type RosterSnapshot struct {
+	Room         int64
+	Self         int64
+	Version      uint64 // 每次变化加一
+	Participants []RosterParticipant
+}
+
+// Roster 会议室参与者名单，根据发布者 handle 收到的 videoroom 事件维护
+// 事件和通知都在 handle 的事件协程中按顺序处理
+type Roster struct {
+	lock         sync.Mutex
+	room         int64
+	self         int64
+	version      uint64
+	participants map[int64]*RosterParticipant
+	onChange     func(RosterEvent)
+}
+
+// NewRoster 在发布者 handle 上维护会议室参与者名单，需在 join 之前创建
+func NewRoster(h *Handle) (r *Roster) {
+	r = &Roster{participants: make(map[int64]*RosterParticipant)}
+	h.stateLock.Lock()
+	h.roster = r
+	h.stateLock.Unlock()
+	return
+}
+
+// Roster 返回 handle 上的参与者名单，没有时为 nil
+func (h *Handle) Roster() *Roster {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	return h.roster
+}
+
+// OnChange 设置参与者变化通知，在 handle 的事件协程中调用，不能阻塞
+func (r *Roster) OnChange(f func(RosterEvent)) *Roster {
+	r.lock.Lock()
+	r.onChange = f
+	r.lock.Unlock()
+	return r
+}
+
+// Snapshot 返回当前状态的副本
+func (r *Roster) Snapshot() (snap RosterSnapshot) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	snap.Room, snap.Self, snap.Version = r.room, r.self, r.version
+	for _, p := range r.participants {
+		snap.Participants = append(snap.Participants, p.clone())
+	}
+	sort.Slice(snap.Participants, func(i, j int) bool { return snap.Participants[i].ID < snap.Participants[j].ID })
+	return
+}
+
+// Participant 返回参与者状态
+func (r *Roster) Participant(id int64) (p RosterParticipant, ok bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if item := r.participants[id]; item != nil {
+		return item.clone(), true
+	}
+	return
+}
+
+func (p *RosterParticipant) clone() (out RosterParticipant) {
+	out = *p
+	out.Streams = append([]RosterStream(nil), p.Streams...)
+	return
+}
+
+// rosterMessage 名单关心的 videoroom 事件字段，leaving 和 unpublished 为 id 或 "ok"
+type rosterMessage struct {
+	VideoRoom       string           `json:"videoroom"`
+	Room            int64            `json:"room"`
+	ID              int64            `json:"id"`
+	Publishers      []VideoPublisher `json:"publishers"`
+	Attendees       []VideoPublisher `json:"attendees"`
+	Joining         *VideoPublisher  `json:"joining"`
+	Unpublished     json.RawMessage  `json:"unpublished"`
+	Leaving         json.RawMessage  `json:"leaving"`
+	Kicked          int64            `json:"kicked"`
+	AudioModeration string           `json:"audio-moderation"`
+	VideoModeration string           `json:"video-moderation"`
+	DataModeration  string           `json:"data-moderation"`
+	Moderation      string           `json:"moderation"` // janus 1.x，按 mid 禁言
+	Mid             string           `json:"mid"`
+}
+
+// participantID leaving/unpublished 为参与者 id 时返回 id，为 "ok" 表示自己
+func participantID(raw json.RawMessage) (id int64, self bool) {
+	if len(raw) == 0 {
+		return
+	}
+	if json.Unmarshal(raw, &id) == nil {
+		return id, false
+	}
+	return 0, true
+}
+
+// apply 处理 videoroom 事件，在 handle 事件协程中调用
+func (r *Roster) apply(data json.RawMessage) {
+	var msg rosterMessage
+	var events []RosterEvent
+	json.Unmarshal(data, &msg)
+	r.lock.Lock()
+	switch msg.VideoRoom {
+	case "joined":
+		events = r.reset()
+		r.room, r.self = msg.Room, msg.ID
+		for i := range msg.Attendees {
+			events = append(events, r.join(&msg.Attendees[i])...)
+		}
+		for i := range msg.Publishers {
+			events = append(events, r.publish(&msg.Publishers[i])...)
+		}
+	case "talking", "stopped-talking":
+		if p := r.participants[msg.ID]; p != nil && p.Talking != (msg.VideoRoom == "talking") {
+			p.Talking = !p.Talking
+			events = append(events, r.change(RosterUpdated, p))
+		}
+	case "destroyed":
+		events = r.reset()
+	case "event":
+		if msg.Joining != nil {
+			events = append(events, r.join(msg.Joining)...)
+		}
+		for i := range msg.Publishers {
+			events = append(events, r.publish(&msg.Publishers[i])...)
+		}
+		if id, _ := participantID(msg.Unpublished); id != 0 {
+			events = append(events, r.unpublish(id)...)
+		}
+		if id, self := participantID(msg.Leaving); id != 0 {
+			events = append(events, r.leave(id)...)
+		} else if self {
+			events = append(events, r.reset()...)
+		}
+		if msg.Kicked != 0 {
+			events = append(events, r.leave(msg.Kicked)...)
+		}
+		events = append(events, r.moderate(&msg)...)
+	}
+	f := r.onChange
+	r.lock.Unlock()
+	if f != nil {
+		for _, ev := range events {
+			f(ev)
+		}
+	}
+}
+
+// change 记录变化，需持有锁
+func (r *Roster) change(change RosterChange, p *RosterParticipant) RosterEvent {
+	r.version++
+	return RosterEvent{Change: change, Room: r.room, Participant: p.clone()}
+}
+
+// join 参与者加入，已存在时更新显示名，需持有锁
+func (r *Roster) join(pub *VideoPublisher) (events []RosterEvent) {
+	if pub.ID == 0 || pub.ID == r.self {
+		return
+	}
+	if p := r.participants[pub.ID]; p != nil {
+		if pub.Display != "" && pub.Display != p.Display {
+			p.Display = pub.Display
+			events = append(events, r.change(RosterUpdated, p))
+		}
+		return
+	}
+	p := &RosterParticipant{ID: pub.ID, Display: pub.Display, Talking: pub.Talking}
+	r.participants[p.ID] = p
+	return append(events, r.change(RosterJoined, p))
+}
+
+// publish 参与者开始发布或更新发布的流，需持有锁
+func (r *Roster) publish(pub *VideoPublisher) (events []RosterEvent) {
+	if pub.ID == 0 || pub.ID == r.self {
+		return
+	}
+	events = r.join(pub)
+	p := r.participants[pub.ID]
+	streams := rosterStreams(pub, p.Streams)
+	if !p.Published {
+		p.Published, p.Streams, p.Talking = true, streams, pub.Talking
+		return append(events, r.change(RosterPublished, p))
+	}
+	if !sameStreams(p.Streams, streams) || (pub.Display != "" && pub.Display != p.Display) {
+		p.Streams = streams
+		if pub.Display != "" {
+			p.Display = pub.Display
+		}
+		events = append(events, r.change(RosterUpdated, p))
+	}
+	return
+}
+
+// unpublish 参与者停止发布，需持有锁
+func (r *Roster) unpublish(id int64) (events []RosterEvent) {
+	if p := r.participants[id]; p != nil && p.Published {
+		p.Published, p.Streams, p.Talking = false, nil, false
+		events = append(events, r.change(RosterUnpublished, p))
+	}
+	return
+}
+
+// leave 参与者离开，还在发布时先通知停止发布，需持有锁
+func (r *Roster) leave(id int64) (events []RosterEvent) {
+	if p := r.participants[id]; p != nil {
+		events = r.unpublish(id)
+		delete(r.participants, id)
+		events = append(events, r.change(RosterLeft, p))
+	}
+	return
+}
+
+// reset 自己离开或会议室销毁，所有参与者视为离开，需持有锁
+func (r *Roster) reset() (events []RosterEvent) {
+	var ids []int64
+	for id := range r.participants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		events = append(events, r.leave(id)...)
+	}
+	return
+}
+
+// moderate 管理员禁止或恢复参与者的流，需持有锁
+func (r *Roster) moderate(msg *rosterMessage) (events []RosterEvent) {
+	var p = r.participants[msg.ID]
+	var changed bool
+	if p == nil {
+		return
+	}
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		state := map[string]string{"audio": msg.AudioModeration, "video": msg.VideoModeration, "data": msg.DataModeration}[s.Type]
+		if msg.Moderation != "" && msg.Mid != "" && msg.Mid == s.Mid {
+			state = msg.Moderation
+		}
+		if state != "" && s.Muted != (state == "muted") {
+			s.Muted = state == "muted"
+			changed = true
+		}
+	}
+	if changed {
+		events = append(events, r.change(RosterUpdated, p))
+	}
+	return
+}
+
+// rosterStreams 从发布者信息得到流列表，janus 0.x 没有 streams 时按 audio_codec/video_codec 生成并保留原有的禁言状态
+func rosterStreams(pub *VideoPublisher, old []RosterStream) (streams []RosterStream) {
+	if len(pub.Streams) > 0 {
+		for _, s := range pub.Streams {
+			streams = append(streams, RosterStream{Type: s.Type, Mid: s.Mid, Codec: s.Codec, Simulcast: s.Simulcast, Disabled: s.Disabled, Muted: s.Moderated})
+		}
+		return
+	}
+	if pub.AudioCodec != "" {
+		streams = append(streams, RosterStream{Type: "audio", Codec: pub.AudioCodec})
+	}
+	if pub.VideoCodec != "" {
+		streams = append(streams, RosterStream{Type: "video", Codec: pub.VideoCodec, Simulcast: pub.Simulcast})
+	}
+	for i := range streams {
+		for _, o := range old {
+			if o.Type == streams[i].Type && o.Mid == streams[i].Mid && o.Muted {
+				streams[i].Muted = true
+			}
+		}
+	}
+	return
+}
+
+func sameStreams(a, b []RosterStream) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// HandleState handle 生命周期状态
+type HandleState string
+
+// handle state defined
+const (
+	HandleStateAttached    HandleState = "attached"    // 已绑定插件
+	HandleStateNegotiating HandleState = "negotiating" // 已发送或收到 SDP，等待 PeerConnection 建立
+	HandleStateWebRTCUp    HandleState = "webrtc-up"   // PeerConnection 已建立
+	HandleStateHungup      HandleState = "hungup"      // PeerConnection 已断开，可重新协商
+	HandleStateDetached    HandleState = "detached"    // 已解绑，终止状态
+)
+
+// SessionState 会话生命周期状态
+type SessionState string
+
+// session state defined
+const (
+	SessionStateConnecting   SessionState = "connecting"   // 已连接服务器，正在创建会话
+	SessionStateActive       SessionState = "active"       // 会话可用
+	SessionStateReconnecting SessionState = "reconnecting" // 连接断开，正在重连
+	SessionStateClosed       SessionState = "closed"       // 会话已释放或丢失，终止状态
+)
+
+// stateWatch 带通知的状态，状态变化时关闭 changed 唤醒所有等待者
+type stateWatch struct {
+	lock     sync.Mutex
+	state    string
+	terminal string
+	closed   error // 等待时进入终止状态返回的错误
+	changed  chan struct{}
+}
+
+func newStateWatch(state, terminal string, closed error) *stateWatch {
+	return &stateWatch{state: state, terminal: terminal, closed: closed, changed: make(chan struct{})}
+}
+
+func (sw *stateWatch) get() string {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	return sw.state
+}
+
+// set 修改状态，from 不为空时只从这些状态转换，已到终止状态后不再变化，返回是否变化
+func (sw *stateWatch) set(state string, from ...string) (changed bool) {
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	if sw.state == state || sw.state == sw.terminal {
+		return false
+	}
+	if len(from) > 0 {
+		var ok bool
+		for _, item := range from {
+			ok = ok || sw.state == item
+		}
+		if !ok {
+			return false
+		}
+	}
+	sw.state = state
+	close(sw.changed)
+	sw.changed = make(chan struct{})
+	return true
+}
+
+// wait 等待进入 state，进入终止状态或 ctx 结束时返回错误
+func (sw *stateWatch) wait(ctx context.Context, state string) (err error) {
+	for {
+		sw.lock.Lock()
+		current, changed := sw.state, sw.changed
+		sw.lock.Unlock()
+		if current == state {
+			return nil
+		}
+		if current == sw.terminal {
+			return fmt.Errorf("%w: state %s reached while waiting for %s", sw.closed, current, state)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return contextError(ctx, "wait state "+state, "")
+		}
+	}
+}
+
+// State 返回 handle 当前状态
+func (h *Handle) State() HandleState {
+	return HandleState(h.state.get())
+}
+
+// WaitState 等待 handle 进入指定状态，handle 解绑时返回 ErrHandleNotFound，ctx 结束时返回错误
+func (h *Handle) WaitState(ctx context.Context, state HandleState) error {
+	return h.state.wait(ctx, string(state))
+}
+
+func (h *Handle) setState(state HandleState, from ...HandleState) {
+	var states []string
+	for _, item := range from {
+		states = append(states, string(item))
+	}
+	if h.state.set(string(state), states...) {
+		h.log(LevelDebug, "handle state", F("state", string(state)))
+	}
+}
+
+// State 返回会话当前状态
+func (js *Janus) State() SessionState {
+	return SessionState(js.state.get())
+}
+
+// WaitState 等待会话进入指定状态，会话关闭时返回 ErrSessionNotFound，ctx 结束时返回错误
+func (js *Janus) WaitState(ctx context.Context, state SessionState) error {
+	return js.state.wait(ctx, string(state))
+}
+
+func (js *Janus) setState(state SessionState) {
+	if js.state.set(string(state)) {
+		js.log(LevelDebug, "session state", F("state", string(state)))
+	}
+}
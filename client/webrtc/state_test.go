@@ -0,0 +1,138 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client/webrtc/janustest"
+)
+
+func TestHandleStateLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := h.State(); s != HandleStateAttached {
+		t.Fatalf("state %s, want %s", s, HandleStateAttached)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	steps := []struct {
+		push func()
+		want HandleState
+	}{
+		{func() {
+			srv.NotifyPlugin(h.GetID(), map[string]interface{}{"videoroom": "event"}, map[string]interface{}{"type": "offer", "sdp": janustest.FakeSDP})
+		}, HandleStateNegotiating},
+		{func() { srv.Notify(h.GetID(), "webrtcup", nil) }, HandleStateWebRTCUp},
+		{func() { srv.Notify(h.GetID(), "hangup", map[string]interface{}{"reason": "test"}) }, HandleStateHungup},
+	}
+	for _, step := range steps {
+		step.push()
+		if err = h.WaitState(ctx, step.want); err != nil {
+			t.Fatalf("wait %s: %v", step.want, err)
+		}
+	}
+	if err = h.Detach(); err != nil {
+		t.Fatal(err)
+	}
+	if s := h.State(); s != HandleStateDetached {
+		t.Fatalf("state %s, want %s", s, HandleStateDetached)
+	}
+	// 终止状态不再变化，等待其他状态返回 ErrHandleNotFound
+	h.setState(HandleStateAttached)
+	if err = h.WaitState(ctx, HandleStateWebRTCUp); !errors.Is(err, ErrHandleNotFound) {
+		t.Fatalf("got %v, want ErrHandleNotFound", err)
+	}
+}
+
+func TestHandleWaitStateConcurrent(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- h.WaitState(ctx, HandleStateWebRTCUp)
+			h.State()
+		}()
+	}
+	srv.Notify(h.GetID(), "webrtcup", nil)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWaitStateTimeout(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err = h.WaitState(ctx, HandleStateWebRTCUp); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+	canceled, stop := context.WithCancel(context.Background())
+	stop()
+	var ce *CanceledError
+	if err = js.WaitState(canceled, SessionStateReconnecting); !errors.As(err, &ce) {
+		t.Fatalf("got %v, want CanceledError", err)
+	}
+}
+
+func TestSessionStateLifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	// 重连等待足够长，保证等待 Reconnecting 的协程能观察到
+	cli := NewClient(srv.URL(), "", WithLogger(NopLogger())).SetReconnect(ReconnectPolicy{Attempts: 3, MinBackoff: 100 * time.Millisecond})
+	js, err := cli.NewJanus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := js.State(); s != SessionStateActive {
+		t.Fatalf("state %s, want %s", s, SessionStateActive)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	reconnecting := make(chan error, 1)
+	go func() { reconnecting <- js.WaitState(ctx, SessionStateReconnecting) }()
+	// 等待协程开始等待之后再断开，Reconnecting 状态可能很短
+	time.Sleep(20 * time.Millisecond)
+	srv.DropConnections()
+	if err = <-reconnecting; err != nil {
+		t.Fatal(err)
+	}
+	if err = js.WaitState(ctx, SessionStateActive); err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- js.WaitState(ctx, SessionStateReconnecting) }()
+	if err = js.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s := js.State(); s != SessionStateClosed {
+		t.Fatalf("state %s, want %s", s, SessionStateClosed)
+	}
+	if err = <-closed; !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("got %v, want ErrSessionNotFound", err)
+	}
+}
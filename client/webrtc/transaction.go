@@ -0,0 +1,182 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Transaction 一个请求的响应关联
+// janus 对异步请求先回 ack 再发 event，也可能先发 event 再回 ack，两者都按 transaction 关联到同一个 Transaction；
+// 同一 transaction 可能有多个事件，如 SIP calling 之后的 ringing，事件按收到的顺序缓存，由 Next 依次取出
+type Transaction struct {
+	id      string
+	verb    string
+	start   time.Time
+	lock    sync.Mutex
+	reply   *JanusResponse // ack、success 或 error
+	replied chan struct{}
+	events  []*JanusResponse
+	notify  chan struct{}
+	closed  bool
+	release func()
+}
+
+func newTransaction(id, verb string) *Transaction {
+	return &Transaction{
+		id:      id,
+		verb:    verb,
+		start:   time.Now(),
+		replied: make(chan struct{}),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// ID transaction id
+func (t *Transaction) ID() string {
+	return t.id
+}
+
+// deliver 读协程收到该 transaction 的消息，只接收第一个响应，重复的响应丢弃
+func (t *Transaction) deliver(msg *JanusResponse, event bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return
+	}
+	if event {
+		t.events = append(t.events, msg)
+		select {
+		case t.notify <- struct{}{}:
+		default:
+		}
+		return
+	}
+	if t.reply == nil {
+		t.reply = msg
+		close(t.replied)
+	}
+}
+
+// Reply 等待 ack 或同步响应
+func (t *Transaction) Reply(ctx context.Context) (resp *JanusResponse, err error) {
+	select {
+	case <-t.replied:
+		t.lock.Lock()
+		resp = t.reply
+		t.lock.Unlock()
+	case <-ctx.Done():
+		err = contextError(ctx, t.verb, t.id)
+	}
+	return
+}
+
+// Next 按收到的顺序取出下一个事件，没有事件时等待，同步请求不会有事件
+func (t *Transaction) Next(ctx context.Context) (ev *JanusResponse, err error) {
+	for {
+		t.lock.Lock()
+		if len(t.events) > 0 {
+			ev = t.events[0]
+			t.events = t.events[1:]
+			t.lock.Unlock()
+			return
+		}
+		closed := t.closed
+		t.lock.Unlock()
+		if closed {
+			return nil, ErrTransactionClosed
+		}
+		select {
+		case <-t.notify:
+		case <-ctx.Done():
+			return nil, contextError(ctx, t.verb, t.id)
+		}
+	}
+}
+
+// Close 停止接收该 transaction 的消息，可重复调用
+func (t *Transaction) Close() {
+	t.lock.Lock()
+	release := t.release
+	t.closed, t.release = true, nil
+	t.lock.Unlock()
+	if release != nil {
+		release()
+	}
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// begin 发送请求并登记 Transaction，之后的响应和事件都交给它，使用完需 Close
+func (js *Janus) begin(ctx context.Context, req *janusRequest) (t *Transaction, err error) {
+	var msg []byte
+	var tr = js.transport()
+	if tr == nil {
+		err = &ConnError{Request: req.Janus}
+		return
+	}
+	if req.Transaction == "" {
+		req.Transaction = js.newTransactionID()
+	}
+	if err = js.authorize(ctx, req); err != nil {
+		err = fmt.Errorf("janus %s credentials fail:%w", req.Janus, err)
+		return
+	}
+	if msg, err = json.Marshal(req); err != nil {
+		return
+	}
+	t = newTransaction(req.Transaction, req.Janus)
+	t.release = func() {
+		js.txns.Delete(t.id)
+	}
+	js.txns.Store(t.id, t)
+	if err = tr.Write(req.SessionID, req.HandleID, msg); err != nil {
+		t.Close()
+		t, err = nil, &ConnError{Request: req.Janus, Err: err}
+		return
+	}
+	js.logRequest(req)
+	js.observeRequest(req, msg)
+	return
+}
+
+// correlate 把带 transaction 的消息交给等待的请求，event 为 janus 事件类消息，只有插件 event 属于请求
+func (js *Janus) correlate(msg *JanusResponse, event bool) {
+	if event && msg.Janus != "event" {
+		return
+	}
+	if value, ok := js.txns.Load(msg.Transaction); ok {
+		if t, ok := value.(*Transaction); ok {
+			t.deliver(msg, event)
+		}
+	}
+}
+
+// SendTransaction 发送消息给插件，返回 ack 或同步响应后的 Transaction，用 Next 依次接收同一 transaction 的事件，使用完需 Close
+func (h *Handle) SendTransaction(ctx context.Context, reqBody interface{}, jsep *Jsep) (t *Transaction, resp *JanusResponse, err error) {
+	var req janusRequest
+	req.Janus = "message"
+	req.SessionID = h.js.GetSessionID()
+	req.HandleID = h.GetID()
+	req.Body = reqBody
+	req.Jsep = jsep
+	if jsep != nil {
+		h.setState(HandleStateNegotiating, HandleStateAttached, HandleStateHungup)
+	}
+	if t, err = h.js.begin(ctx, &req); err != nil {
+		err = fmt.Errorf("handle %s send message fail:%w", h.GetTag(), err)
+		return
+	}
+	if resp, err = h.js.awaitReply(ctx, t, &req); err == nil {
+		err = resp.HasError()
+	}
+	if err != nil {
+		t.Close()
+		t = nil
+	}
+	return
+}
@@ -0,0 +1,200 @@
+package webrtc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client/webrtc/janustest"
+)
+
+// testEvent 模拟 videoroom 插件对 req 的事件
+func testEvent(req *janustest.Request, data map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"janus":       "event",
+		"session_id":  req.SessionID,
+		"sender":      req.HandleID,
+		"transaction": req.Transaction,
+		"plugindata":  map[string]interface{}{"plugin": PluginVideoRoom, "data": data},
+	}
+}
+
+func testAck(req *janustest.Request) map[string]interface{} {
+	return map[string]interface{}{"janus": "ack", "session_id": req.SessionID, "transaction": req.Transaction}
+}
+
+// replyMessages message 请求按 reply 返回的顺序回复
+func replyMessages(srv *janustest.Server, reply func(req *janustest.Request) []map[string]interface{}) {
+	srv.OnRequest("message", func(c *janustest.Conn, req *janustest.Request) bool {
+		for _, msg := range reply(req) {
+			c.Send(msg)
+		}
+		return true
+	})
+}
+
+func pendingTransactions(js *Janus) (n int) {
+	js.txns.Range(func(key, value interface{}) bool {
+		n++
+		return true
+	})
+	return
+}
+
+func TestSendEventBeforeAndAfterAck(t *testing.T) {
+	for _, eventFirst := range []bool{false, true} {
+		srv := newTestServer(t)
+		js := newTestJanus(t, srv)
+		h, err := js.Attach(PluginVideoRoom, "txn")
+		if err != nil {
+			t.Fatal(err)
+		}
+		replyMessages(srv, func(req *janustest.Request) []map[string]interface{} {
+			event := testEvent(req, map[string]interface{}{"videoroom": "event", "configured": "ok"})
+			if eventFirst {
+				return []map[string]interface{}{event, testAck(req)}
+			}
+			return []map[string]interface{}{testAck(req), event}
+		})
+		var resp struct {
+			Configured string `json:"configured"`
+		}
+		if _, err = h.Send(&VideoRoomCommon{Request: "configure"}, nil, &resp); err != nil {
+			t.Fatalf("event first %v: %v", eventFirst, err)
+		}
+		if resp.Configured != "ok" {
+			t.Fatalf("event first %v: configured %q", eventFirst, resp.Configured)
+		}
+		if n := pendingTransactions(js); n != 0 {
+			t.Fatalf("event first %v: %d transactions left", eventFirst, n)
+		}
+	}
+}
+
+func TestSendTransactionEvents(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "txn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyMessages(srv, func(req *janustest.Request) []map[string]interface{} {
+		return []map[string]interface{}{
+			testEvent(req, map[string]interface{}{"step": "calling"}),
+			testAck(req),
+			testAck(req), // 重复的响应被忽略
+			testEvent(req, map[string]interface{}{"step": "ringing"}),
+			testEvent(req, map[string]interface{}{"step": "accepted"}),
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	txn, resp, err := h.SendTransaction(ctx, &VideoRoomCommon{Request: "call"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Janus != "ack" {
+		t.Fatalf("reply %s, want ack", resp.Janus)
+	}
+	for _, want := range []string{"calling", "ringing", "accepted"} {
+		ev, err := txn.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var data struct {
+			Step string `json:"step"`
+		}
+		if err = json.Unmarshal(ev.PluginData.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		if data.Step != want {
+			t.Fatalf("event %q, want %q", data.Step, want)
+		}
+	}
+	txn.Close()
+	txn.Close()
+	if _, err = txn.Next(ctx); !errors.Is(err, ErrTransactionClosed) {
+		t.Fatalf("Next after Close got %v, want ErrTransactionClosed", err)
+	}
+	if n := pendingTransactions(js); n != 0 {
+		t.Fatalf("%d transactions left after Close", n)
+	}
+}
+
+func TestTransactionCloseOnce(t *testing.T) {
+	var released int
+	txn := newTransaction("t1", "message")
+	txn.release = func() { released++ }
+	txn.deliver(&JanusResponse{Janus: "event"}, true)
+	txn.Close()
+	txn.Close()
+	if released != 1 {
+		t.Fatalf("released %d times, want 1", released)
+	}
+	// 关闭之后的消息丢弃，缓存的事件仍可取出
+	txn.deliver(&JanusResponse{Janus: "ack"}, false)
+	txn.deliver(&JanusResponse{Janus: "event"}, true)
+	if _, err := txn.Next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txn.Next(context.Background()); !errors.Is(err, ErrTransactionClosed) {
+		t.Fatalf("got %v, want ErrTransactionClosed", err)
+	}
+	select {
+	case <-txn.replied:
+		t.Fatal("reply delivered after Close")
+	default:
+	}
+}
+
+func TestTransactionTimeoutReleases(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	h, err := js.Attach(PluginVideoRoom, "txn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.DropNext("message")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	txn, _, err := h.SendTransaction(ctx, &VideoRoomCommon{Request: "configure"}, nil)
+	if !errors.Is(err, ErrTimeout) || txn != nil {
+		t.Fatalf("got %v %v, want ErrTimeout", txn, err)
+	}
+	if n := pendingTransactions(js); n != 0 {
+		t.Fatalf("%d transactions left after timeout", n)
+	}
+}
+
+func TestCorrelateIgnoresOtherEvents(t *testing.T) {
+	srv := newTestServer(t)
+	js := newTestJanus(t, srv)
+	req := janusRequest{Janus: "message", SessionID: js.GetSessionID()}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// 服务器不回复，响应和事件由测试直接交给 correlate
+	srv.DropNext("message")
+	txn, err := js.begin(ctx, &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer txn.Close()
+	// 带 transaction 的非插件事件不属于请求
+	js.correlate(&JanusResponse{Janus: "webrtcup", Transaction: req.Transaction}, true)
+	js.correlate(&JanusResponse{Janus: "event", Transaction: "other"}, true)
+	js.correlate(&JanusResponse{Janus: "event", Transaction: req.Transaction}, true)
+	js.correlate(&JanusResponse{Janus: "ack", Transaction: req.Transaction}, false)
+	if resp, err := txn.Reply(ctx); err != nil || resp.Janus != "ack" {
+		t.Fatalf("reply %v %v, want ack", resp, err)
+	}
+	if ev, err := txn.Next(ctx); err != nil || ev.Janus != "event" {
+		t.Fatalf("next %v %v, want event", ev, err)
+	}
+	short, stop := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer stop()
+	if _, err := txn.Next(short); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want no more events", err)
+	}
+}
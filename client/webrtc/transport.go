@@ -0,0 +1,116 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport janus 传输层，负责发送请求和读取服务器推送的消息
+type Transport interface {
+	// Write 发送一条 janus 请求，sessionID/handleID 供需要按路径寻址的传输使用
+	Write(sessionID, handleID int64, msg []byte) error
+	// Read 阻塞读取下一条服务器消息，返回错误表示连接已断开
+	Read() ([]byte, error)
+	// Close 关闭传输
+	Close() error
+}
+
+// TransportDialer 按服务器地址创建传输
+type TransportDialer func(ctx context.Context, server string) (Transport, error)
+
+// dialTransport 根据地址 scheme 选择传输，ws/wss 使用 WebSocket，http/https 使用 HTTP 长轮询，设置了录制时包装传输
+func (cli *Client) dialTransport(ctx context.Context) (tr Transport, err error) {
+	if cli.dialer != nil {
+		tr, err = cli.dialer(ctx, cli.Server)
+	} else {
+		tr, err = cli.dialServer(ctx, cli.Server, false)
+	}
+	if err == nil && cli.recorder != nil {
+		tr = cli.recorder.wrap(tr)
+	}
+	return
+}
+
+// dialServer admin 为 true 时连接 admin/monitor 接口，WebSocket 使用 janus-admin-protocol 子协议，HTTP 不做长轮询
+func (cli *Client) dialServer(ctx context.Context, server string, admin bool) (tr Transport, err error) {
+	var u *url.URL
+	if u, err = url.Parse(server); err != nil {
+		return
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ws", "wss":
+		var ws *wsTransport
+		var protocol = "janus-protocol"
+		if admin {
+			protocol = "janus-admin-protocol"
+		}
+		if ws, err = dialWebSocket(ctx, cli.wsDialer, server, protocol, cli.header); err == nil {
+			if cli.readLimit > 0 {
+				ws.conn.SetReadLimit(cli.readLimit)
+			}
+			tr = ws
+		}
+	case "http", "https":
+		var ht *httpTransport
+		if ht, err = newHTTPTransport(cli.httpClient, server, AuthFunc(cli.credentials)); err == nil {
+			ht.noPoll = admin
+			ht.header = cli.header
+			ht.readLimit = cli.readLimit
+			tr = ht
+		}
+	default:
+		err = fmt.Errorf("unsupported janus server scheme %q", u.Scheme)
+	}
+	return
+}
+
+// wsTransport WebSocket 传输
+type wsTransport struct {
+	conn  *websocket.Conn
+	wlock sync.Mutex
+}
+
+// DialWebSocket 使用 janus-protocol 子协议连接 janus WebSocket 接口
+func DialWebSocket(ctx context.Context, server string) (Transport, error) {
+	return dialWebSocket(ctx, websocket.DefaultDialer, server, "janus-protocol", nil)
+}
+
+func dialWebSocket(ctx context.Context, dialer *websocket.Dialer, server, protocol string, header http.Header) (tr *wsTransport, err error) {
+	var h = header.Clone()
+	var conn *websocket.Conn
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set("Sec-WebSocket-Protocol", protocol)
+	if conn, _, err = dialer.DialContext(ctx, server, h); err != nil {
+		return
+	}
+	tr = &wsTransport{conn: conn}
+	return
+}
+
+func (ws *wsTransport) Write(sessionID, handleID int64, msg []byte) (err error) {
+	ws.wlock.Lock()
+	err = ws.conn.WriteMessage(websocket.TextMessage, msg)
+	ws.wlock.Unlock()
+	return
+}
+
+func (ws *wsTransport) Read() (msg []byte, err error) {
+	_, msg, err = ws.conn.ReadMessage()
+	return
+}
+
+func (ws *wsTransport) Close() (err error) {
+	ws.wlock.Lock()
+	ws.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	ws.wlock.Unlock()
+	err = ws.conn.Close()
+	return
+}
@@ -0,0 +1,218 @@
+package webrtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport janus REST 接口传输，请求用 POST 发送，事件通过 GET 长轮询获取
+type httpTransport struct {
+	base      string
+	auth      AuthProvider
+	hc        *http.Client
+	noPoll    bool // admin 接口没有事件推送
+	header    http.Header
+	readLimit int64
+	incoming  chan []byte
+	ctx       context.Context
+	cancel    context.CancelFunc
+	pollOnce  sync.Once
+	closeOnce sync.Once
+	err       error
+	errLock   sync.Mutex
+}
+
+// NewHTTPTransport 创建 HTTP 长轮询传输，server 为 janus REST 根地址，如 http://127.0.0.1:8088/janus
+func NewHTTPTransport(server, secret string) (Transport, error) {
+	return newHTTPTransport(&http.Client{}, server, StaticSecret(secret))
+}
+
+func newHTTPTransport(hc *http.Client, server string, auth AuthProvider) (ht *httpTransport, err error) {
+	if _, err = url.Parse(server); err != nil {
+		return
+	}
+	ht = &httpTransport{
+		base:     strings.TrimSuffix(server, "/"),
+		auth:     auth,
+		hc:       hc,
+		incoming: make(chan []byte, 64),
+	}
+	ht.ctx, ht.cancel = context.WithCancel(context.Background())
+	return
+}
+
+func (ht *httpTransport) path(sessionID, handleID int64) string {
+	var out = ht.base
+	if sessionID != 0 {
+		out += "/" + strconv.FormatInt(sessionID, 10)
+		if handleID != 0 {
+			out += "/" + strconv.FormatInt(handleID, 10)
+		}
+	}
+	return out
+}
+
+func (ht *httpTransport) Write(sessionID, handleID int64, msg []byte) (err error) {
+	var req *http.Request
+	var resp *http.Response
+	var body []byte
+	if err = ht.closedErr(); err != nil {
+		return
+	}
+	if sessionID != 0 && !ht.noPoll {
+		ht.pollOnce.Do(func() {
+			go ht.poll(sessionID)
+		})
+	}
+	req, err = http.NewRequestWithContext(ht.ctx, http.MethodPost, ht.path(sessionID, handleID), bytes.NewReader(msg))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err = ht.do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if body, err = ht.readBody(resp); err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("janus http status %d: %s", resp.StatusCode, string(body))
+		return
+	}
+	ht.push(body)
+	return
+}
+
+func (ht *httpTransport) do(req *http.Request) (*http.Response, error) {
+	for key, values := range ht.header {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	return ht.hc.Do(req)
+}
+
+func (ht *httpTransport) readBody(resp *http.Response) (body []byte, err error) {
+	if ht.readLimit <= 0 {
+		return ioutil.ReadAll(resp.Body)
+	}
+	if body, err = ioutil.ReadAll(io.LimitReader(resp.Body, ht.readLimit+1)); err == nil && int64(len(body)) > ht.readLimit {
+		err = fmt.Errorf("janus http message exceeds read limit %d", ht.readLimit)
+	}
+	return
+}
+
+func (ht *httpTransport) Read() (msg []byte, err error) {
+	select {
+	case msg = <-ht.incoming:
+	case <-ht.ctx.Done():
+		err = ht.closedErr()
+	}
+	return
+}
+
+func (ht *httpTransport) Close() error {
+	ht.shutdown(fmt.Errorf("http transport closed"))
+	return nil
+}
+
+func (ht *httpTransport) shutdown(err error) {
+	ht.closeOnce.Do(func() {
+		ht.errLock.Lock()
+		ht.err = err
+		ht.errLock.Unlock()
+		ht.cancel()
+	})
+}
+
+func (ht *httpTransport) closedErr() (err error) {
+	ht.errLock.Lock()
+	err = ht.err
+	ht.errLock.Unlock()
+	return
+}
+
+func (ht *httpTransport) push(msg []byte) {
+	select {
+	case ht.incoming <- msg:
+	case <-ht.ctx.Done():
+	}
+}
+
+// poll 长轮询会话事件，网络错误时关闭传输，由上层按连接断开处理
+func (ht *httpTransport) poll(sessionID int64) {
+	for {
+		var q = url.Values{}
+		q.Set("maxev", "10")
+		q.Set("rid", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10))
+		cred, err := ht.auth.Credentials(ht.ctx, sessionID)
+		if err != nil {
+			ht.shutdown(fmt.Errorf("janus long poll credentials fail:%w", err))
+			return
+		}
+		if cred.APISecret != "" {
+			q.Set("apisecret", cred.APISecret)
+		}
+		if cred.Token != "" {
+			q.Set("token", cred.Token)
+		}
+		req, err := http.NewRequestWithContext(ht.ctx, http.MethodGet, ht.path(sessionID, 0)+"?"+q.Encode(), nil)
+		if err != nil {
+			ht.shutdown(err)
+			return
+		}
+		resp, err := ht.do(req)
+		if err != nil {
+			ht.shutdown(fmt.Errorf("janus long poll fail:%w", err))
+			return
+		}
+		body, err := ht.readBody(resp)
+		resp.Body.Close()
+		if err != nil {
+			ht.shutdown(fmt.Errorf("janus long poll read fail:%w", err))
+			return
+		}
+		if resp.StatusCode != http.StatusOK {
+			ht.shutdown(fmt.Errorf("janus long poll status %d: %s", resp.StatusCode, string(body)))
+			return
+		}
+		ht.dispatch(body)
+	}
+}
+
+// dispatch 长轮询可能返回单个事件或事件数组，空闲时服务器返回不带 transaction 的 keepalive
+func (ht *httpTransport) dispatch(body []byte) {
+	var events []json.RawMessage
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return
+	}
+	if body[0] == '[' {
+		if json.Unmarshal(body, &events) != nil {
+			return
+		}
+	} else {
+		events = append(events, body)
+	}
+	for _, ev := range events {
+		var head struct {
+			Janus       string `json:"janus"`
+			Transaction string `json:"transaction"`
+		}
+		if json.Unmarshal(ev, &head) == nil && head.Janus == "keepalive" && head.Transaction == "" {
+			continue
+		}
+		ht.push(ev)
+	}
+}
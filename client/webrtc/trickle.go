@@ -0,0 +1,91 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+
+	pion "github.com/pion/webrtc/v3"
+)
+
+// ICECandidate trickle 候选地址，Completed 为 true 表示候选地址收集结束
+type ICECandidate struct {
+	SdpMid        string  `json:"sdpMid,omitempty"`
+	SdpMLineIndex *uint16 `json:"sdpMLineIndex,omitempty"`
+	Candidate     string  `json:"candidate,omitempty"`
+	Completed     bool    `json:"completed,omitempty"`
+}
+
+// Trickle janus 推送的远端候选地址
+type Trickle struct {
+	eventHeader
+	Candidate ICECandidate
+}
+
+// Trickle 发送本地候选地址给 janus
+func (h *Handle) Trickle(candidate ICECandidate) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return h.sendTrickle(ctx, &candidate)
+}
+
+// TrickleComplete 通知 janus 本地候选地址收集结束
+func (h *Handle) TrickleComplete() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultRequestTimeout)
+	defer cancel()
+	return h.sendTrickle(ctx, &ICECandidate{Completed: true})
+}
+
+func (h *Handle) sendTrickle(ctx context.Context, candidate *ICECandidate) (err error) {
+	var req janusRequest
+	var resp *JanusResponse
+	req.Janus = "trickle"
+	req.SessionID = h.js.GetSessionID()
+	req.HandleID = h.GetID()
+	req.Candidate = candidate
+	if resp, err = h.js.requestContext(ctx, &req); err != nil {
+		err = fmt.Errorf("handle %s trickle fail:%w", h.GetTag(), err)
+		return
+	}
+	err = resp.HasError("trickle")
+	return
+}
+
+// OnRemoteCandidate 设置收到 janus 远端候选地址时的回调，与 SetEventCallBack 的回调在同一协程中按顺序调用
+func (h *Handle) OnRemoteCandidate(f func(*ICECandidate)) *Handle {
+	h.stateLock.Lock()
+	h.onCandidate = f
+	h.stateLock.Unlock()
+	return h
+}
+
+// TrickleICE 把 PeerConnection 收集到的本地候选地址通过 trickle 发给 janus，janus 推送的远端候选地址加入 PeerConnection
+func TrickleICE(pc *pion.PeerConnection, h *Handle) {
+	pc.OnICECandidate(func(c *pion.ICECandidate) {
+		var err error
+		if c == nil {
+			err = h.TrickleComplete()
+		} else {
+			init := c.ToJSON()
+			candidate := ICECandidate{Candidate: init.Candidate, SdpMLineIndex: init.SDPMLineIndex}
+			if init.SDPMid != nil {
+				candidate.SdpMid = *init.SDPMid
+			}
+			err = h.Trickle(candidate)
+		}
+		if err != nil {
+			h.log(LevelWarn, "trickle local candidate fail", F(FieldError, err))
+		}
+	})
+	h.OnRemoteCandidate(func(c *ICECandidate) {
+		if c.Completed {
+			return
+		}
+		init := pion.ICECandidateInit{Candidate: c.Candidate, SDPMLineIndex: c.SdpMLineIndex}
+		if c.SdpMid != "" {
+			init.SDPMid = &c.SdpMid
+		}
+		if err := pc.AddICECandidate(init); err != nil {
+			h.log(LevelWarn, "add remote candidate fail", F(FieldError, err))
+		}
+	})
+}
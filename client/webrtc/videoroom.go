@@ -9,45 +9,64 @@ import (
 // VideoRoomResponse 视频会议插件事件响应，各种响应定义放到一起
 type VideoRoomResponse struct {
 	PluginRespError
-	VideoRoom      string             `json:"videoroom,omitempty"` // created,edited,destroyed
-	Room           int64              `json:"room"`
-	Permanent      bool               `json:"permanent"`
-	Exists         bool               `json:"exists"`
-	CurrentBitrate int                `json:"current-bitrate,omitempty"` // for slow_link
-	Unpublished    int64              `json:"unpublished,omitempty"`
-	Leaving        int64              `json:"leaving,omitempty"`
-	Description    string             `json:"description,omitempty"`
-	ID             int64              `json:"id,omitempty"`
-	PrivateID      int64              `json:"private_id,omitempty"`
-	Allowed        []string           `json:"allowed,omitempty"`
-	List           []RoomInfo         `json:"list,omitempty"`
-	Participants   []ParticipantsInfo `json:"participants,omitempty"`
-	Publishers     []VideoPublisher   `json:"publishers,omitempty"`
-	Attendees      []VideoPublisher   `json:"attendees,omitempty"` // only id and display
-	Switched       string             `json:"switched,omitempty"`
-	Data           json.RawMessage    `json:"data,omitempty"`
-	AudioLevelAvg  float64            `json:"audio-level-dBov-avg,omitempty"`
-	RelayData      string             `json:"relay_data,omitempty"`
+	VideoRoom      string               `json:"videoroom,omitempty"` // created,edited,destroyed
+	Room           int64                `json:"room"`
+	Permanent      bool                 `json:"permanent"`
+	Exists         bool                 `json:"exists"`
+	CurrentBitrate int                  `json:"current-bitrate,omitempty"` // for slow_link
+	Unpublished    int64                `json:"unpublished,omitempty"`
+	Leaving        int64                `json:"leaving,omitempty"`
+	Description    string               `json:"description,omitempty"`
+	ID             int64                `json:"id,omitempty"`
+	PrivateID      int64                `json:"private_id,omitempty"`
+	Allowed        []string             `json:"allowed,omitempty"`
+	List           []RoomInfo           `json:"list,omitempty"`
+	Participants   []ParticipantsInfo   `json:"participants,omitempty"`
+	Publishers     []VideoPublisher     `json:"publishers,omitempty"`
+	Attendees      []VideoPublisher     `json:"attendees,omitempty"` // only id and display
+	Joining        *VideoPublisher      `json:"joining,omitempty"`   // notify_joining 时新加入的参与者
+	Switched       string               `json:"switched,omitempty"`
+	Streams        []VideoRoomSubStream `json:"streams,omitempty"` // janus 1.x 订阅的 attached,updated 事件中的 mid 和发布者对应关系
+	Data           json.RawMessage      `json:"data,omitempty"`
+	AudioLevelAvg  float64              `json:"audio-level-dBov-avg,omitempty"`
+	RelayData      string               `json:"relay_data,omitempty"`
 }
 
-// VideoRoomCreate 创建视频会议室请求
+// VideoRoomCreate 创建视频会议室请求，可用 NewVideoRoomBuilder 构造并校验
 type VideoRoomCreate struct {
 	Request            string   `json:"request,omitempty"`
 	Room               int64    `json:"room,omitempty"`
 	Permanent          bool     `json:"permanent"`
 	Description        string   `json:"description,omitempty"`
-	Publishers         *int     `json:"publishers,omitempty"`           // max number of concurrent senders, default = 3
-	Secret             string   `json:"secret,omitempty"`               // password required to edit/destroy the room, optional
-	Pin                string   `json:"pin,omitempty"`                  // password required to join the room, optional
-	IsPrivate          bool     `json:"is_private"`                     // whether the room should appear in a list request
-	Allowed            []string `json:"allowed,omitempty"`              // array of string tokens users can use to join this room, optional
-	AdminKey           string   `json:"admin_key,omitempty"`            // 插件配置中有 admin_key 时，只有正确的 key 才能创建会议室
-	AudiolevelEvent    *bool    `json:"audiolevel_event,omitempty"`     // (whether to emit event to other users or not, default=false)
-	AudioLevelAverage  *int     `json:"audio_level_average,omitempty"`  // (average value of audio level, 127=muted, 0='too loud', default=25)
-	AudioActivePackets *int     `json:"audio_active_packets,omitempty"` // (number of packets with audio level, default=100, 2 seconds)
-	OpusFec            *bool    `json:"opus_fec,omitempty"`             // whether inband FEC must be negotiated; only works for Opus, default=false
-	AudioCodec         string   `json:"audiocodec,omitempty"`           // opus|g722|pcmu|pcma|isac32|isac16
-	VideoCodec         string   `json:"videocodec,omitempty"`           // vp8|vp9|h264|av1|h265
+	Publishers         *int     `json:"publishers,omitempty"`            // max number of concurrent senders, default = 3
+	Secret             string   `json:"secret,omitempty"`                // password required to edit/destroy the room, optional
+	Pin                string   `json:"pin,omitempty"`                   // password required to join the room, optional
+	IsPrivate          bool     `json:"is_private"`                      // whether the room should appear in a list request
+	Allowed            []string `json:"allowed,omitempty"`               // array of string tokens users can use to join this room, optional
+	AdminKey           string   `json:"admin_key,omitempty"`             // 插件配置中有 admin_key 时，只有正确的 key 才能创建会议室
+	RequirePvtID       *bool    `json:"require_pvtid,omitempty"`         // whether subscriptions are required to provide a valid private_id, default=false
+	RequireE2EE        *bool    `json:"require_e2ee,omitempty"`          // whether all participants are required to publish and subscribe using end-to-end media encryption, default=false
+	NotifyJoining      *bool    `json:"notify_joining,omitempty"`        // whether to notify all participants when a new participant joins the room, default=false
+	Bitrate            *int     `json:"bitrate,omitempty"`               // max video bitrate for senders (e.g., 128000)
+	BitrateCap         *bool    `json:"bitrate_cap,omitempty"`           // whether the above cap should act as a limit to dynamic bitrate changes by publishers, default=false
+	FirFreq            *int     `json:"fir_freq,omitempty"`              // send a FIR to publishers every fir_freq seconds (0=disable)
+	AudiolevelExt      *bool    `json:"audiolevel_ext,omitempty"`        // whether the ssrc-audio-level RTP extension must be negotiated, default=true
+	AudiolevelEvent    *bool    `json:"audiolevel_event,omitempty"`      // (whether to emit event to other users or not, default=false)
+	AudioLevelAverage  *int     `json:"audio_level_average,omitempty"`   // (average value of audio level, 127=muted, 0='too loud', default=25)
+	AudioActivePackets *int     `json:"audio_active_packets,omitempty"`  // (number of packets with audio level, default=100, 2 seconds)
+	OpusFec            *bool    `json:"opus_fec,omitempty"`              // whether inband FEC must be negotiated; only works for Opus, default=false
+	OpusDtx            *bool    `json:"opus_dtx,omitempty"`              // whether DTX must be negotiated; only works for Opus, default=false
+	VideoSvc           *bool    `json:"video_svc,omitempty"`             // whether SVC support must be enabled; only works for VP9, default=false
+	AudioCodec         string   `json:"audiocodec,omitempty"`            // opus|g722|pcmu|pcma|isac32|isac16
+	VideoCodec         string   `json:"videocodec,omitempty"`            // vp8|vp9|h264|av1|h265
+	VP9Profile         string   `json:"vp9_profile,omitempty"`           // VP9-specific profile to prefer (e.g., "2" for "profile-id=2")
+	H264Profile        string   `json:"h264_profile,omitempty"`          // H.264-specific profile to prefer (e.g., "42e01f" for "profile-level-id=42e01f")
+	VideoOrientExt     *bool    `json:"videoorient_ext,omitempty"`       // whether the video-orientation RTP extension must be negotiated/used or not, default=true
+	PlayoutDelayExt    *bool    `json:"playoutdelay_ext,omitempty"`      // whether the playout-delay RTP extension must be negotiated/used or not, default=true
+	TransportWideCCExt *bool    `json:"transport_wide_cc_ext,omitempty"` // whether the transport wide CC RTP extension must be negotiated/used or not, default=true
+	Record             *bool    `json:"record,omitempty"`                // whether this room should be recorded, default=false
+	RecDir             string   `json:"rec_dir,omitempty"`               // folder where recordings should be stored, when enabled
+	LockRecord         *bool    `json:"lock_record,omitempty"`           // whether recording can only be started/stopped if the secret is provided, default=false
 }
 
 // Prepare 准备请求
@@ -66,17 +85,22 @@ func (vrc *VideoRoomCreate) Prepare(adminKey string, permanent bool, desc, secre
 	vrc.AudioLevelAverage = client.Int(50)
 }
 
-// VideoRoomEdit 修改视频会议室配置，只支持部分属性配置
+// VideoRoomEdit 修改视频会议室配置，new_* 为空时不修改
 type VideoRoomEdit struct {
-	Request        string `json:"request"`
-	Room           int64  `json:"room"`
-	Secret         string `json:"secret,omitempty"`
-	NewDescription string `json:"new_description,omitempty"`
-	NewSecret      string `json:"new_secret,omitempty"`
-	NewPin         string `json:"new_pin,omitempty"`
-	NewIsPrivate   bool   `json:"new_is_private,omitempty"`
-	Permanent      bool   `json:"permanent"`
-	// more ...
+	Request         string `json:"request"`
+	Room            int64  `json:"room"`
+	Secret          string `json:"secret,omitempty"`
+	NewDescription  string `json:"new_description,omitempty"`
+	NewSecret       string `json:"new_secret,omitempty"`
+	NewPin          string `json:"new_pin,omitempty"`
+	NewIsPrivate    *bool  `json:"new_is_private,omitempty"`
+	NewRequirePvtID *bool  `json:"new_require_pvtid,omitempty"`
+	NewBitrate      *int   `json:"new_bitrate,omitempty"`
+	NewFirFreq      *int   `json:"new_fir_freq,omitempty"`
+	NewPublishers   *int   `json:"new_publishers,omitempty"`
+	NewLockRecord   *bool  `json:"new_lock_record,omitempty"`
+	NewRecDir       string `json:"new_rec_dir,omitempty"`
+	Permanent       bool   `json:"permanent"`
 }
 
 // VideoRoomDestroy can be used to destroy an existing video room, whether created dynamically or statically
@@ -145,27 +169,30 @@ type ParticipantsInfo struct {
 
 // VideoRoomJoin join as publisher or subscriber
 type VideoRoomJoin struct {
-	Request       string `json:"request"`
-	Ptype         string `json:"ptype"`
-	Room          int64  `json:"room"`
-	Pin           string `json:"pin,omitempty"`
-	ID            int64  `json:"id,omitempty"`
-	Display       string `json:"display,omitempty"`
-	Token         string `json:"token,omitempty"`
-	Feed          int64  `json:"feed,omitempty"` // subscriber
-	PrivateID     int64  `json:"private_id,omitempty"`
-	ClosePC       *bool  `json:"close_pc,omitempty"`
-	Audio         *bool  `json:"audio,omitempty"`
-	Video         *bool  `json:"video,omitempty"`
-	Data          *bool  `json:"data,omitempty"`
-	OfferAudio    *bool  `json:"offer_audio,omitempty"`
-	OfferVideo    *bool  `json:"offer_video,omitempty"`
-	OfferData     *bool  `json:"offer_data,omitempty"`
-	SubStream     int    `json:"substream,omitempty"`      // substream to receive (0-2), in case simulcasting is enabled; optional
-	Temporal      int    `json:"temporal,omitempty"`       // temporal layers to receive (0-2), in case simulcasting is enabled; optional
-	Fallback      int    `json:"fallback,omitempty"`       // How much time (in us, default 250000) without receiving packets will make us drop to the substream below
-	SpatialLayer  int    `json:"spatial_layer,omitempty"`  // spatial layer to receive (0-2), in case VP9-SVC is enabled; optional
-	TemporalLayer int    `json:"temporal_layer,omitempty"` // temporal layers to receive (0-2), in case VP9-SVC is enabled; optional
+	Request       string                     `json:"request"`
+	Ptype         string                     `json:"ptype"`
+	Room          int64                      `json:"room"`
+	Pin           string                     `json:"pin,omitempty"`
+	ID            int64                      `json:"id,omitempty"`
+	Display       string                     `json:"display,omitempty"`
+	Token         string                     `json:"token,omitempty"`
+	Feed          int64                      `json:"feed,omitempty"`       // subscriber
+	Streams       []VideoRoomSubscribeStream `json:"streams,omitempty"`    // janus 1.x 多流订阅
+	UseMsid       *bool                      `json:"use_msid,omitempty"`   // janus 1.x，订阅的 msid 使用发布者的 msid
+	AutoUpdate    *bool                      `json:"autoupdate,omitempty"` // janus 1.x，发布者变化时是否自动重新协商，默认 true
+	PrivateID     int64                      `json:"private_id,omitempty"`
+	ClosePC       *bool                      `json:"close_pc,omitempty"`
+	Audio         *bool                      `json:"audio,omitempty"`
+	Video         *bool                      `json:"video,omitempty"`
+	Data          *bool                      `json:"data,omitempty"`
+	OfferAudio    *bool                      `json:"offer_audio,omitempty"`
+	OfferVideo    *bool                      `json:"offer_video,omitempty"`
+	OfferData     *bool                      `json:"offer_data,omitempty"`
+	SubStream     int                        `json:"substream,omitempty"`      // substream to receive (0-2), in case simulcasting is enabled; optional
+	Temporal      int                        `json:"temporal,omitempty"`       // temporal layers to receive (0-2), in case simulcasting is enabled; optional
+	Fallback      int                        `json:"fallback,omitempty"`       // How much time (in us, default 250000) without receiving packets will make us drop to the substream below
+	SpatialLayer  int                        `json:"spatial_layer,omitempty"`  // spatial layer to receive (0-2), in case VP9-SVC is enabled; optional
+	TemporalLayer int                        `json:"temporal_layer,omitempty"` // temporal layers to receive (0-2), in case VP9-SVC is enabled; optional
 }
 
 // AsPublisher join as publisher
@@ -193,14 +220,69 @@ func (vrj *VideoRoomJoin) AsSubscriber(roomID, feedID int64) {
 	vrj.OfferData = client.Bool(true)
 }
 
+// AsMultiSubscriber janus 1.x 以多流订阅者加入，一个 handle 订阅多个发布者的流
+func (vrj *VideoRoomJoin) AsMultiSubscriber(roomID int64, streams ...VideoRoomSubscribeStream) {
+	vrj.Request = "join"
+	vrj.Ptype = "subscriber"
+	vrj.Room = roomID
+	vrj.Streams = streams
+}
+
+// VideoRoomSubscribeStream janus 1.x 订阅或取消订阅的流，Mid 为空时表示发布者的所有流
+type VideoRoomSubscribeStream struct {
+	Feed       int64  `json:"feed,omitempty"`
+	Mid        string `json:"mid,omitempty"`        // 发布者的 mid
+	SubMid     string `json:"sub_mid,omitempty"`    // 取消订阅时可指定订阅方的 mid
+	CrossRefID string `json:"crossrefid,omitempty"` // 返回的 streams 中原样带回，用于关联请求
+}
+
+// VideoRoomSubStream janus 1.x 订阅 PeerConnection 中的一路流，Mid 为订阅方的 mid
+type VideoRoomSubStream struct {
+	Mindex      int    `json:"mindex"`
+	Mid         string `json:"mid"`
+	Type        string `json:"type"` // audio,video,data
+	Active      bool   `json:"active"`
+	FeedID      int64  `json:"feed_id,omitempty"`
+	FeedMid     string `json:"feed_mid,omitempty"`
+	FeedDisplay string `json:"feed_display,omitempty"`
+	Send        bool   `json:"send,omitempty"`
+	Ready       bool   `json:"ready,omitempty"`
+	Codec       string `json:"codec,omitempty"`
+	CrossRefID  string `json:"crossrefid,omitempty"`
+}
+
+// VideoRoomSubscription janus 1.x 修改订阅，request 为 subscribe,unsubscribe 时使用 Streams，为 update 时使用 Subscribe 和 Unsubscribe
+// 订阅变化后 janus 在 updated 事件中发送新的 offer
+type VideoRoomSubscription struct {
+	Request     string                     `json:"request"`
+	Streams     []VideoRoomSubscribeStream `json:"streams,omitempty"`
+	Subscribe   []VideoRoomSubscribeStream `json:"subscribe,omitempty"`
+	Unsubscribe []VideoRoomSubscribeStream `json:"unsubscribe,omitempty"`
+}
+
 // VideoPublisher 视频会议发布者信息
 type VideoPublisher struct {
-	ID         int64  `json:"id"`
-	Display    string `json:"display"`
-	AudioCodec string `json:"audio_codec,omitempty"`
-	VideoCodec string `json:"video_codec,omitempty"`
-	Simulcast  bool   `json:"simulcast,omitempty"`
-	Talking    bool   `json:"talking,omitempty"`
+	ID         int64             `json:"id"`
+	Display    string            `json:"display"`
+	AudioCodec string            `json:"audio_codec,omitempty"`
+	VideoCodec string            `json:"video_codec,omitempty"`
+	Simulcast  bool              `json:"simulcast,omitempty"`
+	Talking    bool              `json:"talking,omitempty"`
+	Streams    []VideoRoomStream `json:"streams,omitempty"` // janus 1.x
+}
+
+// VideoRoomStream 发布者的一路媒体流，janus 1.x 在 publishers 中返回
+type VideoRoomStream struct {
+	Type        string `json:"type"` // audio,video,data
+	Mindex      int    `json:"mindex"`
+	Mid         string `json:"mid"`
+	Disabled    bool   `json:"disabled,omitempty"`
+	Codec       string `json:"codec,omitempty"`
+	Description string `json:"description,omitempty"`
+	Simulcast   bool   `json:"simulcast,omitempty"`
+	SVC         bool   `json:"svc,omitempty"`
+	Talking     bool   `json:"talking,omitempty"`
+	Moderated   bool   `json:"moderated,omitempty"`
 }
 
 // VideoRoomPublish publish video
@@ -0,0 +1,300 @@
+package webrtc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/finove/webrtctest/client"
+)
+
+// videoroom 支持的编码，audiocodec/videocodec 为按优先级排列的逗号分隔列表
+var (
+	videoRoomAudioCodecs = []string{"opus", "multiopus", "g722", "pcmu", "pcma", "isac32", "isac16"}
+	videoRoomVideoCodecs = []string{"vp8", "vp9", "h264", "av1", "h265"}
+)
+
+// videoRoomMaxCodecs 编码列表最多包含的编码数
+const videoRoomMaxCodecs = 5
+
+// Validate 发送前检查请求参数，错误可用 errors.Is(err, ErrInvalidRoomOption) 判断
+func (vrc *VideoRoomCreate) Validate() (err error) {
+	var video []string
+	if vrc.Room < 0 {
+		return invalidRoomOption("room", vrc.Room)
+	}
+	if err = checkPositive("publishers", vrc.Publishers); err != nil {
+		return
+	}
+	if err = checkNonNegative("bitrate", vrc.Bitrate); err != nil {
+		return
+	}
+	if err = checkNonNegative("fir_freq", vrc.FirFreq); err != nil {
+		return
+	}
+	if vrc.AudioLevelAverage != nil && (*vrc.AudioLevelAverage < 0 || *vrc.AudioLevelAverage > 127) {
+		return invalidRoomOption("audio_level_average", *vrc.AudioLevelAverage)
+	}
+	if err = checkPositive("audio_active_packets", vrc.AudioActivePackets); err != nil {
+		return
+	}
+	if _, err = parseCodecs("audiocodec", vrc.AudioCodec, videoRoomAudioCodecs); err != nil {
+		return
+	}
+	if video, err = parseCodecs("videocodec", vrc.VideoCodec, videoRoomVideoCodecs); err != nil {
+		return
+	}
+	if vrc.VP9Profile != "" {
+		if !containsString(video, "vp9") {
+			return fmt.Errorf("%w: vp9_profile requires vp9 in videocodec", ErrInvalidRoomOption)
+		}
+		if len(vrc.VP9Profile) != 1 || vrc.VP9Profile[0] < '0' || vrc.VP9Profile[0] > '3' {
+			return invalidRoomOption("vp9_profile", vrc.VP9Profile)
+		}
+	}
+	if vrc.H264Profile != "" {
+		if !containsString(video, "h264") {
+			return fmt.Errorf("%w: h264_profile requires h264 in videocodec", ErrInvalidRoomOption)
+		}
+		if !isHex(vrc.H264Profile, 6) {
+			return invalidRoomOption("h264_profile", vrc.H264Profile)
+		}
+	}
+	if vrc.VideoSvc != nil && *vrc.VideoSvc && !containsString(video, "vp9") {
+		return fmt.Errorf("%w: video_svc requires vp9 in videocodec", ErrInvalidRoomOption)
+	}
+	return
+}
+
+// Validate 发送前检查请求参数，错误可用 errors.Is(err, ErrInvalidRoomOption) 判断
+func (vre *VideoRoomEdit) Validate() (err error) {
+	if vre.Room <= 0 {
+		return invalidRoomOption("room", vre.Room)
+	}
+	if err = checkPositive("new_publishers", vre.NewPublishers); err != nil {
+		return
+	}
+	if err = checkNonNegative("new_bitrate", vre.NewBitrate); err != nil {
+		return
+	}
+	return checkNonNegative("new_fir_freq", vre.NewFirFreq)
+}
+
+func invalidRoomOption(name string, value interface{}) error {
+	return fmt.Errorf("%w: invalid %s %v", ErrInvalidRoomOption, name, value)
+}
+
+func checkPositive(name string, value *int) error {
+	if value != nil && *value <= 0 {
+		return invalidRoomOption(name, *value)
+	}
+	return nil
+}
+
+func checkNonNegative(name string, value *int) error {
+	if value != nil && *value < 0 {
+		return invalidRoomOption(name, *value)
+	}
+	return nil
+}
+
+// parseCodecs 解析逗号分隔的编码列表，不支持的编码、重复或超过数量限制时返回错误
+func parseCodecs(name, list string, supported []string) (codecs []string, err error) {
+	if list == "" {
+		return
+	}
+	for _, codec := range strings.Split(list, ",") {
+		codec = strings.TrimSpace(codec)
+		if !containsString(supported, codec) {
+			return nil, fmt.Errorf("%w: %s %q not supported, available %v", ErrInvalidRoomOption, name, codec, supported)
+		}
+		if containsString(codecs, codec) {
+			return nil, fmt.Errorf("%w: %s %q duplicated", ErrInvalidRoomOption, name, codec)
+		}
+		codecs = append(codecs, codec)
+	}
+	if len(codecs) > videoRoomMaxCodecs {
+		return nil, fmt.Errorf("%w: %s has %d codecs, max %d", ErrInvalidRoomOption, name, len(codecs), videoRoomMaxCodecs)
+	}
+	return
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range strings.ToLower(s) {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// VideoRoomBuilder 构造创建会议室请求，Build 时校验参数
+type VideoRoomBuilder struct {
+	req VideoRoomCreate
+}
+
+// NewVideoRoomBuilder 创建会议室请求构造器，room 为 0 时由插件分配
+func NewVideoRoomBuilder(room int64) *VideoRoomBuilder {
+	return &VideoRoomBuilder{req: VideoRoomCreate{Request: "create", Room: room}}
+}
+
+// Description 会议室描述
+func (b *VideoRoomBuilder) Description(desc string) *VideoRoomBuilder {
+	b.req.Description = desc
+	return b
+}
+
+// Permanent 是否保存到插件配置文件
+func (b *VideoRoomBuilder) Permanent(permanent bool) *VideoRoomBuilder {
+	b.req.Permanent = permanent
+	return b
+}
+
+// Secret 修改和销毁会议室需要的密码
+func (b *VideoRoomBuilder) Secret(secret string) *VideoRoomBuilder {
+	b.req.Secret = secret
+	return b
+}
+
+// Pin 加入会议室需要的密码
+func (b *VideoRoomBuilder) Pin(pin string) *VideoRoomBuilder {
+	b.req.Pin = pin
+	return b
+}
+
+// Private 不出现在 list 结果中
+func (b *VideoRoomBuilder) Private(private bool) *VideoRoomBuilder {
+	b.req.IsPrivate = private
+	return b
+}
+
+// Allowed 允许加入的 token 列表
+func (b *VideoRoomBuilder) Allowed(tokens ...string) *VideoRoomBuilder {
+	b.req.Allowed = tokens
+	return b
+}
+
+// AdminKey 插件配置了 admin_key 时创建会议室需要的 key
+func (b *VideoRoomBuilder) AdminKey(key string) *VideoRoomBuilder {
+	b.req.AdminKey = key
+	return b
+}
+
+// Publishers 最多同时发布的人数
+func (b *VideoRoomBuilder) Publishers(n int) *VideoRoomBuilder {
+	b.req.Publishers = &n
+	return b
+}
+
+// Bitrate 发布者视频码率上限，limit 为 true 时发布者不能通过 configure 超过上限
+func (b *VideoRoomBuilder) Bitrate(bitrate int, limit bool) *VideoRoomBuilder {
+	b.req.Bitrate = &bitrate
+	b.req.BitrateCap = &limit
+	return b
+}
+
+// FirFreq 每隔多少秒向发布者发送 FIR，0 表示不发送
+func (b *VideoRoomBuilder) FirFreq(seconds int) *VideoRoomBuilder {
+	b.req.FirFreq = &seconds
+	return b
+}
+
+// AudioCodecs 音频编码，按优先级排列
+func (b *VideoRoomBuilder) AudioCodecs(codecs ...string) *VideoRoomBuilder {
+	b.req.AudioCodec = strings.Join(codecs, ",")
+	return b
+}
+
+// VideoCodecs 视频编码，按优先级排列
+func (b *VideoRoomBuilder) VideoCodecs(codecs ...string) *VideoRoomBuilder {
+	b.req.VideoCodec = strings.Join(codecs, ",")
+	return b
+}
+
+// VP9Profile 优先使用的 VP9 profile-id，0-3
+func (b *VideoRoomBuilder) VP9Profile(profile string) *VideoRoomBuilder {
+	b.req.VP9Profile = profile
+	return b
+}
+
+// H264Profile 优先使用的 H.264 profile-level-id，如 42e01f
+func (b *VideoRoomBuilder) H264Profile(profile string) *VideoRoomBuilder {
+	b.req.H264Profile = profile
+	return b
+}
+
+// Opus 是否协商 Opus 的 FEC 和 DTX
+func (b *VideoRoomBuilder) Opus(fec, dtx bool) *VideoRoomBuilder {
+	b.req.OpusFec = &fec
+	b.req.OpusDtx = &dtx
+	return b
+}
+
+// VideoSvc 开启 VP9 SVC
+func (b *VideoRoomBuilder) VideoSvc(enable bool) *VideoRoomBuilder {
+	b.req.VideoSvc = &enable
+	return b
+}
+
+// AudioLevel 开启音量事件，average 为音量阈值(0-127)，packets 为统计的包数
+func (b *VideoRoomBuilder) AudioLevel(average, packets int) *VideoRoomBuilder {
+	b.req.AudiolevelEvent = client.Bool(true)
+	b.req.AudioLevelAverage = &average
+	b.req.AudioActivePackets = &packets
+	return b
+}
+
+// RequirePvtID 订阅时必须提供发布者的 private_id
+func (b *VideoRoomBuilder) RequirePvtID(require bool) *VideoRoomBuilder {
+	b.req.RequirePvtID = &require
+	return b
+}
+
+// RequireE2EE 所有参与者必须使用端到端加密
+func (b *VideoRoomBuilder) RequireE2EE(require bool) *VideoRoomBuilder {
+	b.req.RequireE2EE = &require
+	return b
+}
+
+// NotifyJoining 有人加入时通知所有参与者，包括只订阅的参与者
+func (b *VideoRoomBuilder) NotifyJoining(notify bool) *VideoRoomBuilder {
+	b.req.NotifyJoining = &notify
+	return b
+}
+
+// Extensions 是否协商 audio-level、video-orientation、playout-delay 和 transport-wide-cc RTP 扩展
+func (b *VideoRoomBuilder) Extensions(audioLevel, videoOrient, playoutDelay, transportWideCC bool) *VideoRoomBuilder {
+	b.req.AudiolevelExt = &audioLevel
+	b.req.VideoOrientExt = &videoOrient
+	b.req.PlayoutDelayExt = &playoutDelay
+	b.req.TransportWideCCExt = &transportWideCC
+	return b
+}
+
+// Record 录制会议室，dir 为空时使用插件默认目录，lock 为 true 时开始和停止录制需要 secret
+func (b *VideoRoomBuilder) Record(dir string, lock bool) *VideoRoomBuilder {
+	b.req.Record = client.Bool(true)
+	b.req.RecDir = dir
+	b.req.LockRecord = &lock
+	return b
+}
+
+// Build 校验并返回请求
+func (b *VideoRoomBuilder) Build() (req *VideoRoomCreate, err error) {
+	var out = b.req
+	if err = out.Validate(); err != nil {
+		return
+	}
+	return &out, nil
+}
@@ -0,0 +1,122 @@
+package webrtc
+
+import (
+	"context"
+	"fmt"
+)
+
+// VideoRoomControl 视频会议室管理接口，通过一个 videoroom handle 发送同步请求
+// 插件返回的错误可用 errors.Is 判断，如 ErrRoomNotFound、ErrRoomExists、ErrRoomUnauthorized、ErrNoSuchFeed
+type VideoRoomControl struct {
+	h *Handle
+}
+
+// NewVideoRoomControl 使用已绑定 videoroom 插件的 handle
+func NewVideoRoomControl(h *Handle) *VideoRoomControl {
+	return &VideoRoomControl{h: h}
+}
+
+// VideoRoomControl 绑定一个 videoroom handle 用于管理会议室
+func (js *Janus) VideoRoomControl(ctx context.Context) (vc *VideoRoomControl, err error) {
+	var h *Handle
+	if h, err = js.AttachContext(ctx, PluginVideoRoom, "control"); err != nil {
+		return
+	}
+	return NewVideoRoomControl(h), nil
+}
+
+// Handle 返回管理使用的 handle
+func (vc *VideoRoomControl) Handle() *Handle {
+	return vc.h
+}
+
+// request 发送同步请求并解析响应
+func (vc *VideoRoomControl) request(ctx context.Context, name string, req interface{}) (resp *VideoRoomResponse, err error) {
+	resp = new(VideoRoomResponse)
+	if _, err = vc.h.SendContext(ctx, req, nil, resp); err != nil {
+		err = fmt.Errorf("videoroom %s fail:%w", name, err)
+		resp = nil
+	}
+	return
+}
+
+// CreateRoom 创建会议室，req.Room 为 0 时由插件分配，返回会议室号，发送前校验参数
+func (vc *VideoRoomControl) CreateRoom(ctx context.Context, req *VideoRoomCreate) (room int64, err error) {
+	var resp *VideoRoomResponse
+	req.Request = "create"
+	if err = req.Validate(); err != nil {
+		return
+	}
+	if resp, err = vc.request(ctx, req.Request, req); err != nil {
+		return
+	}
+	return resp.Room, nil
+}
+
+// EditRoom 修改会议室配置，发送前校验参数
+func (vc *VideoRoomControl) EditRoom(ctx context.Context, req *VideoRoomEdit) (err error) {
+	req.Request = "edit"
+	if err = req.Validate(); err != nil {
+		return
+	}
+	_, err = vc.request(ctx, req.Request, req)
+	return
+}
+
+// DestroyRoom 销毁会议室，房间内的参与者会收到 destroyed 事件
+func (vc *VideoRoomControl) DestroyRoom(ctx context.Context, req *VideoRoomDestroy) (err error) {
+	req.Request = "destroy"
+	_, err = vc.request(ctx, req.Request, req)
+	return
+}
+
+// ListRooms 列出公开的会议室
+func (vc *VideoRoomControl) ListRooms(ctx context.Context) (rooms []RoomInfo, err error) {
+	var resp *VideoRoomResponse
+	if resp, err = vc.request(ctx, "list", &VideoRoomCommon{Request: "list"}); err != nil {
+		return
+	}
+	return resp.List, nil
+}
+
+// Exists 会议室是否存在
+func (vc *VideoRoomControl) Exists(ctx context.Context, room int64) (exists bool, err error) {
+	var resp *VideoRoomResponse
+	if resp, err = vc.request(ctx, "exists", &VideoRoomCommon{Request: "exists", Room: room}); err != nil {
+		return
+	}
+	return resp.Exists, nil
+}
+
+// ListParticipants 列出会议室中的参与者
+func (vc *VideoRoomControl) ListParticipants(ctx context.Context, room int64) (participants []ParticipantsInfo, err error) {
+	var resp *VideoRoomResponse
+	if resp, err = vc.request(ctx, "listparticipants", &VideoRoomCommon{Request: "listparticipants", Room: room}); err != nil {
+		return
+	}
+	return resp.Participants, nil
+}
+
+// Kick 把参与者踢出会议室
+func (vc *VideoRoomControl) Kick(ctx context.Context, req *VideoRoomKick) (err error) {
+	req.Request = "kick"
+	_, err = vc.request(ctx, req.Request, req)
+	return
+}
+
+// Allowed 修改会议室允许加入的 token 列表，action 为 enable,disable,add,remove，返回修改后的列表
+func (vc *VideoRoomControl) Allowed(ctx context.Context, req *VideoRoomAllowed) (allowed []string, err error) {
+	var resp *VideoRoomResponse
+	req.Request = "allowed"
+	if resp, err = vc.request(ctx, req.Request, req); err != nil {
+		return
+	}
+	return resp.Allowed, nil
+}
+
+// Moderate 禁止或恢复发布者的音频、视频或数据
+func (vc *VideoRoomControl) Moderate(ctx context.Context, req *VideoRoomModerate) (err error) {
+	req.Request = "moderate"
+	_, err = vc.request(ctx, req.Request, req)
+	return
+}
@@ -0,0 +1,132 @@
+package webrtc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/finove/webrtctest/client"
+)
+
+func newTestControl(t *testing.T) (js *Janus, vc *VideoRoomControl, ctx context.Context) {
+	t.Helper()
+	srv := newTestServer(t)
+	js = newTestJanus(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+	vc, err := js.VideoRoomControl(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js, vc, ctx
+}
+
+func TestVideoRoomControlRoomLifecycle(t *testing.T) {
+	_, vc, ctx := newTestControl(t)
+	room, err := vc.CreateRoom(ctx, &VideoRoomCreate{Description: "control", Secret: "adm", Pin: "1111"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room == 0 {
+		t.Fatal("plugin did not assign a room")
+	}
+	if _, err = vc.CreateRoom(ctx, &VideoRoomCreate{Room: room}); !errors.Is(err, ErrRoomExists) {
+		t.Fatalf("create existing room got %v, want ErrRoomExists", err)
+	}
+	if exists, err := vc.Exists(ctx, room); err != nil || !exists {
+		t.Fatalf("exists %v %v, want true", exists, err)
+	}
+	if err = vc.EditRoom(ctx, &VideoRoomEdit{Room: room, Secret: "wrong", NewDescription: "x"}); !errors.Is(err, ErrRoomUnauthorized) {
+		t.Fatalf("edit with wrong secret got %v, want ErrRoomUnauthorized", err)
+	}
+	if err = vc.EditRoom(ctx, &VideoRoomEdit{Room: room, Secret: "adm", NewDescription: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	rooms, err := vc.ListRooms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found *RoomInfo
+	for i := range rooms {
+		if rooms[i].Room == room {
+			found = &rooms[i]
+		}
+	}
+	if found == nil || found.Description != "edited" || !found.PinRequired {
+		t.Fatalf("room %d not listed as edited with pin: %+v", room, rooms)
+	}
+	if err = vc.DestroyRoom(ctx, &VideoRoomDestroy{Room: room, Secret: "adm"}); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := vc.Exists(ctx, room); err != nil || exists {
+		t.Fatalf("exists %v %v after destroy, want false", exists, err)
+	}
+	if err = vc.DestroyRoom(ctx, &VideoRoomDestroy{Room: room}); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("destroy missing room got %v, want ErrRoomNotFound", err)
+	}
+}
+
+func TestVideoRoomControlValidates(t *testing.T) {
+	_, vc, ctx := newTestControl(t)
+	if _, err := vc.CreateRoom(ctx, &VideoRoomCreate{Publishers: client.Int(0)}); !errors.Is(err, ErrInvalidRoomOption) {
+		t.Fatalf("got %v, want ErrInvalidRoomOption", err)
+	}
+	if err := vc.EditRoom(ctx, &VideoRoomEdit{}); !errors.Is(err, ErrInvalidRoomOption) {
+		t.Fatalf("got %v, want ErrInvalidRoomOption", err)
+	}
+}
+
+func TestVideoRoomControlParticipants(t *testing.T) {
+	js, vc, ctx := newTestControl(t)
+	const room = 1234
+	pub, err := js.AttachContext(ctx, PluginVideoRoom, "publisher")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var join VideoRoomJoin
+	var joined VideoRoomResponse
+	join.AsPublisher(room, "alice")
+	if _, err = pub.SendContext(ctx, &join, nil, &joined); err != nil {
+		t.Fatal(err)
+	}
+	participants, err := vc.ListParticipants(ctx, room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(participants) != 1 || participants[0].ID != joined.ID || participants[0].Display != "alice" {
+		t.Fatalf("participants %+v, want alice %d", participants, joined.ID)
+	}
+	if err = vc.Moderate(ctx, &VideoRoomModerate{Room: room, ID: joined.ID, MuteAudio: client.Bool(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if err = vc.Moderate(ctx, &VideoRoomModerate{Room: room, ID: 1, MuteVideo: client.Bool(true)}); !errors.Is(err, ErrNoSuchFeed) {
+		t.Fatalf("moderate missing feed got %v, want ErrNoSuchFeed", err)
+	}
+	allowed, err := vc.Allowed(ctx, &VideoRoomAllowed{Room: room, Action: "add", Allowed: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed, err = vc.Allowed(ctx, &VideoRoomAllowed{Room: room, Action: "remove", Allowed: []string{"a"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(allowed, []string{"b"}) {
+		t.Fatalf("allowed %v, want [b]", allowed)
+	}
+	if _, err = vc.Allowed(ctx, &VideoRoomAllowed{Room: room, Action: "bogus"}); !errors.Is(err, ErrInvalidRoomOption) {
+		t.Fatalf("bad allowed action got %v, want ErrInvalidRoomOption", err)
+	}
+	if err = vc.Kick(ctx, &VideoRoomKick{Room: room, ID: joined.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if participants, err = vc.ListParticipants(ctx, room); err != nil || len(participants) != 0 {
+		t.Fatalf("participants %+v %v after kick, want none", participants, err)
+	}
+	if err = vc.Kick(ctx, &VideoRoomKick{Room: room, ID: joined.ID}); !errors.Is(err, ErrNoSuchFeed) {
+		t.Fatalf("kick twice got %v, want ErrNoSuchFeed", err)
+	}
+	if _, err = vc.ListParticipants(ctx, 999); !errors.Is(err, ErrRoomNotFound) {
+		t.Fatalf("list missing room got %v, want ErrRoomNotFound", err)
+	}
+}
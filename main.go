@@ -13,19 +13,34 @@ import (
 )
 
 func main() {
-	var isSend, isCli bool
+	var isSend, isCli, isFollow bool
 	var feedID int64
+	var feeds string
 	var cli uClient
 	var err error
 	flag.BoolVar(&isSend, "send", false, "send mode")
 	flag.BoolVar(&isCli, "cli", false, "cli test mode")
 	flag.Int64Var(&feedID, "feed", 0, "video room feed id")
+	flag.BoolVar(&isFollow, "follow", false, "subscribe and record every publisher in room")
+	flag.StringVar(&feeds, "feeds", "", "comma separated feed ids, subscribe with janus 1.x multistream")
 	flag.Parse()
-	if isCli || feedID > 0 {
+	if isCli || feedID > 0 || isFollow || feeds != "" {
 		err = cli.Init(janusAddress, janusSecret)
 		if err != nil {
 			panic(err)
 		}
+		if isFollow {
+			if err = cli.FollowRoom(1234); err != nil {
+				panic(err)
+			}
+			select {}
+		}
+		if feeds != "" {
+			if err = cli.SubscribeStreams(1234, feeds); err != nil {
+				panic(err)
+			}
+			select {}
+		}
 		if feedID == 0 {
 			cli.listparticipants(1234)
 			return
@@ -38,8 +53,7 @@ func main() {
 		// 		URLs: []string{"stun:stun.1.google.com:19302"},
 		// 	},
 		// },
-		SDPSemantics: webrtc.SDPSemanticsPlanB,
-		// SDPSemantics: webrtc.SDPSemanticsUnifiedPlanWithFallback,
+		SDPSemantics:  webrtc.SDPSemanticsUnifiedPlan,
 		RTCPMuxPolicy: webrtc.RTCPMuxPolicyRequire,
 		BundlePolicy:  webrtc.BundlePolicyMaxBundle,
 	}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/finove/webrtctest/client"
 	jns "github.com/finove/webrtctest/client/webrtc"
+	"github.com/pion/webrtc/v3"
 )
 
 var (
@@ -17,9 +21,11 @@ var (
 type uClient struct {
 	janusCli *jns.Client
 	session  *jns.Janus
-	roomCtl  *jns.Handle
+	roomCtl  *jns.VideoRoomControl
 	sub      *jns.Handle
 	pub      *jns.Handle
+	follower *jns.RoomFollower
+	multiSub *jns.MultiSubscriber
 }
 
 func (uc *uClient) Init(server, secret string) (err error) {
@@ -27,19 +33,16 @@ func (uc *uClient) Init(server, secret string) (err error) {
 	if uc.session, err = uc.janusCli.NewJanus(); err != nil {
 		return
 	}
-	uc.roomCtl, err = uc.session.Attach(jns.PluginVideoRoom, "control")
+	uc.roomCtl, err = uc.session.VideoRoomControl(context.Background())
 	return
 }
 
 func (uc *uClient) listparticipants(roomID int64) (err error) {
-	var req jns.VideoRoomCommon
-	var roomResp jns.VideoRoomResponse
-	req.Request = "listparticipants"
-	req.Room = roomID
-	if _, err = uc.roomCtl.Send(&req, nil, &roomResp); err != nil {
+	var participants []jns.ParticipantsInfo
+	if participants, err = uc.roomCtl.ListParticipants(context.Background(), roomID); err != nil {
 		return
 	}
-	log.Printf("%s", client.ShowJSON(roomResp, true))
+	log.Printf("%s", client.ShowJSON(participants, true))
 	return
 }
 
@@ -62,6 +65,36 @@ func (uc *uClient) Subscrite(roomID, feedID int64) (sub *jns.Handle, offer strin
 	return
 }
 
+// FollowRoom 订阅会议室中的所有发布者，轨道保存为 feed-<id>-<kind> 文件
+func (uc *uClient) FollowRoom(roomID int64) (err error) {
+	uc.follower = jns.NewRoomFollower(uc.session, roomID, func(feed jns.RosterParticipant, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		log.Printf("feed %d %s got codec %s", feed.ID, feed.Display, track.Codec().MimeType)
+		SaveRemoteTrack(fmt.Sprintf("feed-%d-%s", feed.ID, track.Kind()), track)
+	}).OnError(func(feed int64, err error) {
+		log.Printf("follow feed %d fail:%v", feed, err)
+	})
+	return uc.follower.Start(context.Background())
+}
+
+// SubscribeStreams janus 1.x 用一个 handle 订阅多个发布者，feeds 为逗号分隔的 feed id
+func (uc *uClient) SubscribeStreams(roomID int64, feeds string) (err error) {
+	var streams []jns.VideoRoomSubscribeStream
+	for _, item := range strings.Split(feeds, ",") {
+		var feed int64
+		if feed, err = strconv.ParseInt(strings.TrimSpace(item), 10, 64); err != nil {
+			return fmt.Errorf("invalid feed %q:%w", item, err)
+		}
+		streams = append(streams, jns.VideoRoomSubscribeStream{Feed: feed})
+	}
+	uc.multiSub = jns.NewMultiSubscriber(uc.session, roomID, func(stream jns.VideoRoomSubStream, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		log.Printf("mid %s feed %d %s got codec %s", stream.Mid, stream.FeedID, stream.FeedDisplay, track.Codec().MimeType)
+		SaveRemoteTrack(fmt.Sprintf("feed-%d-%s", stream.FeedID, stream.FeedMid), track)
+	}).OnError(func(err error) {
+		log.Printf("multistream fail:%v", err)
+	})
+	return uc.multiSub.Subscribe(context.Background(), streams...)
+}
+
 func (uc *uClient) SubscriteStart(sdp string) (err error) {
 	var roomResp jns.VideoRoomResponse
 	var req struct {